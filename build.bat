@@ -17,7 +17,7 @@ if not exist internal\assets mkdir internal\assets
 copy /Y logo.ico internal\assets\
 
 :: Optimize using -ldflags
-go build -ldflags="-s -w -H windowsgui -X cursor_history/internal/app.Version=%VERSION%" -trimpath -o CursorHistory.exe ./
+go build -tags sqlite_fts5 -ldflags="-s -w -H windowsgui -X cursor_history/internal/app.Version=%VERSION%" -trimpath -o CursorHistory.exe ./
 
 :: Create installer directory if not exists
 if not exist installer mkdir installer
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// command 命令行子命令
+type command struct {
+	summary string
+	run     func(args []string) int
+}
+
+// commands 所有可用的子命令
+var commands = map[string]command{
+	"config": {summary: "查看或修改配置项", run: runConfig},
+	"daemon": {summary: "以无界面模式运行监控并上传提示词", run: runDaemon},
+	"export": {summary: "导出提示词为 Markdown、JSON、JSONL、CSV 或 HTML", run: runExport},
+	"outbox": {summary: "查看上传队列状态或重试失败的记录", run: runOutbox},
+	"rules":  {summary: "查看或修改工作区上传规则", run: runRules},
+	"scan":   {summary: "补扫已有的工作区数据库并上传其中的提示词", run: runScan},
+	"search": {summary: "搜索本地归档的提示词", run: runSearch},
+}
+
+// IsCommand 判断参数是否为已知的子命令
+func IsCommand(name string) bool {
+	if name == "help" || name == "-h" || name == "--help" {
+		return true
+	}
+	_, ok := commands[name]
+	return ok
+}
+
+// Run 执行子命令，args[0] 为子命令名称，返回进程退出码
+func Run(args []string) int {
+	if len(args) == 0 {
+		Usage(os.Stderr)
+		return 2
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		if IsCommand(args[0]) {
+			Usage(os.Stdout)
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "未知命令: %s\n\n", args[0])
+		Usage(os.Stderr)
+		return 2
+	}
+	return cmd.run(args[1:])
+}
+
+// Usage 输出命令行用法
+func Usage(w io.Writer) {
+	fmt.Fprintln(w, "用法: cursor_history <命令> [参数]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "命令:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].summary)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "使用 cursor_history <命令> -h 查看命令参数")
+}
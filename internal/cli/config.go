@@ -0,0 +1,261 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cursor_history/internal/control"
+	"cursor_history/internal/logging"
+	"cursor_history/internal/metrics"
+	"cursor_history/internal/policy"
+	"cursor_history/internal/redact"
+	"cursor_history/internal/storage"
+	"cursor_history/internal/upload"
+	"cursor_history/internal/vscdb"
+)
+
+// setting 可通过 config 命令修改的配置项
+type setting struct {
+	summary  string
+	validate func(value string) error
+}
+
+// settings 所有可通过命令行修改的配置项
+var settings = map[string]setting{
+	storage.SettingStorageDirs: {
+		summary: "要监控的 workspaceStorage 目录，多个目录用系统路径分隔符分隔，为空时自动查找",
+	},
+	storage.SettingBatchSize: {
+		summary:  "每批上传的最大记录数",
+		validate: validatePositiveInt,
+	},
+	storage.SettingBatchMaxLatency: {
+		summary:  "新记录等待凑批的最长时间，如 2s",
+		validate: validateDuration,
+	},
+	storage.SettingWatchMode: {
+		summary:  "文件监控方式: auto（默认，fsnotify 不可用时改为轮询）、fsnotify、poll",
+		validate: storage.ValidateWatchMode,
+	},
+	storage.SettingPollInterval: {
+		summary:  "轮询监控的间隔，如 10s",
+		validate: validatePositiveDuration,
+	},
+	storage.SettingDebounceWindow: {
+		summary:  "state.vscdb 停止写入多久后才处理，如 500ms",
+		validate: validateDuration,
+	},
+	storage.SettingDebounceMaxWait: {
+		summary:  "state.vscdb 持续写入时最长等待多久处理一次，如 5s，0 表示不限制",
+		validate: validateDuration,
+	},
+	storage.SettingStartupScan: {
+		summary:  "启动时是否补扫已有的工作区数据库: true（默认）、false",
+		validate: validateBool,
+	},
+	storage.SettingScanSince: {
+		summary:  "启动补扫只处理该时间之后修改过的数据库，如 2024-01-31、720h、30d，为空时全部处理",
+		validate: validateSince,
+	},
+	storage.SettingDedupeScope: {
+		summary:  "去重范围: workspace（默认，不同工作区的相同提示词分别上传）、global（所有工作区只上传一次）",
+		validate: storage.ValidateDedupeScope,
+	},
+	storage.SettingDedupeByDay: {
+		summary:  "不同日期的相同提示词是否分别上传: false（默认）、true，日期为首次读到提示词的日期，之后重新读取不会再次上传",
+		validate: validateBool,
+	},
+	storage.SettingGitDisable: {
+		summary:  "不随提示词上传的 Git 信息，逗号分隔，可用: " + strings.Join(upload.GitFields(), ", "),
+		validate: upload.ValidateGitDisable,
+	},
+	storage.SettingReadMode: {
+		summary:  "读取 state.vscdb 的方式: auto（默认，有 WAL 时读取快照）、snapshot（总是复制快照）、readonly（只读直接打开）",
+		validate: vscdb.ValidateMode,
+	},
+	storage.SettingReadBusyTimeout: {
+		summary:  "读取 state.vscdb 遇到锁时的忙等待时间，如 2s",
+		validate: validateDuration,
+	},
+	storage.SettingReadRetries: {
+		summary:  "读取 state.vscdb 被占用或正在写入时的重试次数，默认 3",
+		validate: validateNonNegativeInt,
+	},
+	storage.SettingLogLevel: {
+		summary:  "日志级别: debug、info（默认）、success、warn、error，可按组件配置，如 info,watcher=debug，组件有 " + strings.Join(append(upload.LogComponents(), control.LogComponent), ", "),
+		validate: logging.ValidateLevels,
+	},
+	storage.SettingLogFormat: {
+		summary:  "日志文件格式: text（默认）、json（每行一个 JSON 对象）",
+		validate: logging.ValidateFormat,
+	},
+	storage.SettingLogMaxSize: {
+		summary:  "单个日志文件的最大大小（MB），超过后轮转，默认 10，0 表示不限制",
+		validate: validateNonNegativeInt,
+	},
+	storage.SettingLogMaxAge: {
+		summary:  "日志文件按该时长轮转，如 24h（默认），0 表示不按时间轮转",
+		validate: validateDuration,
+	},
+	storage.SettingLogMaxBackups: {
+		summary:  "保留的历史日志文件数，默认 7，0 表示全部保留",
+		validate: validateNonNegativeInt,
+	},
+	storage.SettingLogCompress: {
+		summary:  "是否用 gzip 压缩历史日志文件: false（默认）、true",
+		validate: validateBool,
+	},
+	storage.SettingControlAddr: {
+		summary:  "本机控制接口的监听地址，如 127.0.0.1:7611，为空时不启用，令牌保存在配置目录下的 " + control.TokenFile + "，只对 daemon 和 Windows 桌面版生效",
+		validate: control.ValidateAddr,
+	},
+	storage.SettingMetricsAddr: {
+		summary:  "Prometheus 指标接口的监听地址，只能为本机回环地址，如 127.0.0.1:9464，为空时不启用，只对 daemon 和 Windows 桌面版生效",
+		validate: metrics.ValidateAddr,
+	},
+	storage.SettingSinks: {
+		summary:  `上传目标 JSON 数组，如 [{"type":"server"},{"type":"file","path":"out.jsonl"}]，类型有 server、file、webhook、sqlite，为空时只上传到提示词服务器`,
+		validate: upload.ValidateSinkConfigs,
+	},
+	storage.SettingRedactRules: {
+		summary:  `自定义脱敏规则 JSON 数组，如 [{"name":"internal_host","pattern":"\\w+\\.corp\\.example\\.com"}]，有捕获组时只替换第一个捕获组`,
+		validate: redact.ValidateRules,
+	},
+	storage.SettingRedactDisable: {
+		summary:  "要关闭的内置脱敏检测器，逗号分隔，可用: " + strings.Join(redact.Detectors(), ", "),
+		validate: redact.ValidateDisabled,
+	},
+	storage.SettingWorkspaceRules: {
+		summary:  "工作区上传规则 JSON，建议使用 rules 命令修改",
+		validate: policy.Validate,
+	},
+}
+
+// runConfig 查看或修改配置项
+// 不带参数时列出所有配置项，带一个参数时显示该项，带两个参数时修改该项（值为空字符串表示恢复默认）
+func runConfig(args []string) int {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	dbPath := fs.String("db", "", "配置数据库路径（默认为用户配置目录下的 CursorHistory/config.db）")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: cursor_history config [参数] [配置项 [值]]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 2 {
+		fs.Usage()
+		return 2
+	}
+
+	var key string
+	if fs.NArg() > 0 {
+		key = fs.Arg(0)
+		if _, ok := settings[key]; !ok {
+			fmt.Fprintf(os.Stderr, "未知配置项: %s\n", key)
+			return 2
+		}
+	}
+
+	configManager, err := openConfigManager(*dbPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer configManager.Close()
+
+	if fs.NArg() == 2 {
+		value := fs.Arg(1)
+		if validate := settings[key].validate; validate != nil && value != "" {
+			if err := validate(value); err != nil {
+				fmt.Fprintf(os.Stderr, "无效的 %s: %v\n", key, err)
+				return 2
+			}
+		}
+		if err := configManager.SaveSetting(key, value); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	keys := []string{key}
+	if key == "" {
+		keys = make([]string, 0, len(settings))
+		for name := range settings {
+			keys = append(keys, name)
+		}
+		sort.Strings(keys)
+	}
+
+	for _, name := range keys {
+		value, err := configManager.LoadSetting(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if value == "" {
+			value = "(默认)"
+		}
+		fmt.Printf("%-20s %s\n    %s\n", name, value, settings[name].summary)
+	}
+	return 0
+}
+
+func validatePositiveInt(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	if n < 1 {
+		return fmt.Errorf("必须大于 0")
+	}
+	return nil
+}
+
+func validateNonNegativeInt(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return fmt.Errorf("不能为负数")
+	}
+	return nil
+}
+
+func validateDuration(value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	if d < 0 {
+		return fmt.Errorf("不能为负数")
+	}
+	return nil
+}
+
+func validatePositiveDuration(value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	if d <= 0 {
+		return fmt.Errorf("必须大于 0")
+	}
+	return nil
+}
+
+func validateBool(value string) error {
+	_, err := strconv.ParseBool(value)
+	return err
+}
+
+func validateSince(value string) error {
+	_, err := storage.ParseSince(value, time.Now())
+	return err
+}
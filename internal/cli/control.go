@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"cursor_history/internal/control"
+	"cursor_history/internal/source"
+	"cursor_history/internal/storage"
+	"cursor_history/internal/types"
+	"cursor_history/internal/upload"
+)
+
+// daemonMonitor 守护进程的监控，可通过控制接口停止后重新开始
+type daemonMonitor struct {
+	roots         []source.Root
+	configManager *storage.ConfigManager
+	logger        types.Logger
+
+	mu     sync.Mutex
+	cancel context.CancelFunc // 停止当前的监控，未在监控时为 nil
+	done   chan struct{}      // 当前监控结束时关闭，未在监控时为 nil
+}
+
+// StartMonitoring 在后台开始监控
+func (m *daemonMonitor) StartMonitoring() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.done != nil {
+		return fmt.Errorf("监控已在运行")
+	}
+	if m.configManager.GetContext().Err() != nil {
+		return fmt.Errorf("程序正在退出")
+	}
+
+	ctx, cancel := context.WithCancel(m.configManager.GetContext())
+	done := make(chan struct{})
+	m.cancel, m.done = cancel, done
+	go func() {
+		defer close(done)
+		defer cancel()
+		if err := upload.WatchDirectoryContext(ctx, m.roots, m.configManager, m.logger); err != nil {
+			m.logger.Log(types.LogLevelError, "监控发生错误: %v", err)
+		}
+
+		m.mu.Lock()
+		if m.done == done {
+			m.cancel, m.done = nil, nil
+		}
+		m.mu.Unlock()
+	}()
+	return nil
+}
+
+// StopMonitoring 停止监控并等待监控结束
+func (m *daemonMonitor) StopMonitoring() error {
+	m.mu.Lock()
+	cancel, done := m.cancel, m.done
+	m.mu.Unlock()
+
+	if done == nil {
+		return upload.ErrNotMonitoring
+	}
+	cancel()
+	<-done
+	return nil
+}
+
+// wait 等待当前的监控结束
+func (m *daemonMonitor) wait() {
+	m.mu.Lock()
+	done := m.done
+	m.mu.Unlock()
+
+	if done != nil {
+		<-done
+	}
+}
+
+// runWithControl 启动本机控制接口并开始监控，监控可通过控制接口停止和重新开始，直到收到退出信号
+func runWithControl(addr string, roots []source.Root, configManager *storage.ConfigManager, logger types.Logger, logs *control.LogBuffer) int {
+	monitor := &daemonMonitor{roots: roots, configManager: configManager, logger: logger}
+	server, err := control.Start(control.Options{
+		Addr:          addr,
+		ConfigManager: configManager,
+		Monitor:       monitor,
+		Logs:          logs,
+		Logger:        logger,
+	})
+	if err != nil {
+		logger.Log(types.LogLevelError, "%v", err)
+		return 1
+	}
+	defer func() {
+		if err := server.Close(); err != nil {
+			logger.Log(types.LogLevelError, "关闭控制接口失败: %v", err)
+		}
+	}()
+
+	if err := monitor.StartMonitoring(); err != nil {
+		logger.Log(types.LogLevelError, "%v", err)
+		return 1
+	}
+	<-configManager.GetContext().Done()
+	monitor.wait()
+	return 0
+}
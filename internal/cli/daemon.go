@@ -0,0 +1,225 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"cursor_history/internal/app"
+	"cursor_history/internal/control"
+	"cursor_history/internal/logging"
+	"cursor_history/internal/metrics"
+	"cursor_history/internal/source"
+	"cursor_history/internal/storage"
+	"cursor_history/internal/types"
+	"cursor_history/internal/upload"
+)
+
+// runDaemon 无界面运行监控，收到 SIGINT/SIGTERM 后通过 ConfigManager 的 context 退出
+func runDaemon(args []string) int {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	env := fs.String("env", "prod", "运行环境 (prod/dev)，可被 CURSOR_ENV 环境变量覆盖")
+	apiKey := fs.String("api-key", "", "API Key，指定后会验证并保存到配置中；也可通过 CURSOR_HISTORY_API_KEY 设置")
+	dbPath := fs.String("db", "", "配置数据库路径（默认为用户配置目录下的 CursorHistory/config.db）")
+	dir := fs.String("dir", "", "要监控的 workspaceStorage 目录，多个目录用系统路径分隔符分隔（默认自动查找）")
+	logPath := fs.String("log", "", "日志文件路径，按 log_max_size、log_max_age 等配置轮转，为空时只输出到标准输出")
+	logLevel := fs.String("log-level", "", "日志级别，如 debug 或 info,watcher=debug，指定后忽略 log_level 配置")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	var levels *logging.Levels
+	if *logLevel != "" {
+		parsed, err := logging.ParseLevels(*logLevel)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		levels = &parsed
+	}
+
+	console, err := logging.NewConsoleLogger("")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var logger types.Logger = console
+	app.Config.SetLogger(logger)
+	defer app.Config.Stop()
+
+	app.InitApp(*env)
+
+	configManager, err := openConfigManager(*dbPath)
+	if err != nil {
+		logger.Log(types.LogLevelError, "初始化配置管理器失败: %v", err)
+		return 1
+	}
+	defer configManager.Close()
+	if !configManager.FullTextSearch() {
+		logger.Log(types.LogLevelWarning, "当前程序编译时未启用 sqlite_fts5，本地归档的搜索不使用全文索引，改为逐条 LIKE 匹配")
+	}
+
+	// 日志文件的轮转参数保存在配置数据库中，打开数据库后再同时输出到日志文件
+	outputs := []types.Logger{console}
+	if *logPath != "" {
+		fileLogger, err := openFileLogger(*logPath, configManager)
+		if err != nil {
+			logger.Log(types.LogLevelError, "%v", err)
+			return 1
+		}
+		outputs = append(outputs, fileLogger)
+	}
+
+	// 启用控制接口时保留最近的日志供查询
+	controlAddr, err := configManager.LoadSetting(storage.SettingControlAddr)
+	if err != nil {
+		logger.Log(types.LogLevelError, "%v", err)
+		return 1
+	}
+	var logs *control.LogBuffer
+	if controlAddr != "" {
+		logs = control.NewLogBuffer(control.LogBufferSize)
+		outputs = append(outputs, logs)
+	}
+
+	if len(outputs) > 1 {
+		logger = logging.Multi(outputs...)
+		app.Config.SetLogger(logger)
+	}
+
+	// 命令行未指定日志级别时使用配置的级别
+	if levels == nil {
+		loaded, err := configManager.LoadLogLevels()
+		if err != nil {
+			logger.Log(types.LogLevelWarning, "%v，使用默认日志级别", err)
+		}
+		levels = &loaded
+	}
+	logger = logging.New(logger, *levels)
+	app.Config.SetLogger(logger)
+
+	key, err := resolveApiKey(*apiKey, configManager)
+	if err != nil {
+		logger.Log(types.LogLevelError, "%v", err)
+		return 1
+	}
+	app.Config.ApiKey = key
+	if key == "" {
+		logger.Log(types.LogLevelWarning, "未设置 API Key，提示词只保存到本地归档，可使用 -api-key 参数或 CURSOR_HISTORY_API_KEY 环境变量设置")
+	}
+
+	// 收到退出信号后取消 context，WatchDirectory 会随之返回
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case sig := <-signals:
+			logger.Log(types.LogLevelInfo, "收到信号 %v，正在停止监控", sig)
+			configManager.Cancel()
+		case <-configManager.GetContext().Done():
+		}
+	}()
+
+	roots, err := resolveRoots(*dir, configManager)
+	if err != nil {
+		logger.Log(types.LogLevelError, "%v", err)
+		return 1
+	}
+
+	logger.Log(types.LogLevelInfo, "以无界面模式启动，环境: %s", app.GetEnv())
+
+	// 启用指标接口时提供 Prometheus 指标
+	metricsAddr, err := configManager.LoadSetting(storage.SettingMetricsAddr)
+	if err != nil {
+		logger.Log(types.LogLevelError, "%v", err)
+		return 1
+	}
+	if metricsAddr != "" {
+		server, err := metrics.Start(metricsAddr, logger)
+		if err != nil {
+			logger.Log(types.LogLevelError, "%v", err)
+			return 1
+		}
+		defer func() {
+			if err := server.Close(); err != nil {
+				logger.Log(types.LogLevelError, "关闭指标接口失败: %v", err)
+			}
+		}()
+	}
+
+	if controlAddr != "" {
+		return runWithControl(controlAddr, roots, configManager, logger, logs)
+	}
+	if err := upload.WatchDirectory(roots, configManager, logger); err != nil {
+		logger.Log(types.LogLevelError, "监控发生错误: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// openConfigManager 打开配置数据库，dbPath 为空时使用默认路径
+func openConfigManager(dbPath string) (*storage.ConfigManager, error) {
+	if dbPath == "" {
+		var err error
+		dbPath, err = storage.DefaultDBPath()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return storage.NewConfigManager(dbPath)
+}
+
+// openFileLogger 按配置打开可轮转的日志文件
+func openFileLogger(path string, configManager *storage.ConfigManager) (*logging.FileLogger, error) {
+	opts, err := configManager.LoadLogOptions(path)
+	if err != nil {
+		return nil, err
+	}
+	return logging.NewFileLogger(opts)
+}
+
+// resolveRoots 解析要监控的 workspaceStorage 目录，参数优先于已保存的配置
+func resolveRoots(flagDirs string, configManager *storage.ConfigManager) ([]source.Root, error) {
+	override := flagDirs
+	if override == "" {
+		var err error
+		if override, err = configManager.LoadStorageDirs(); err != nil {
+			return nil, err
+		}
+	}
+
+	roots := source.Roots(override)
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("未找到 workspaceStorage 目录，已检查: %s", strings.Join(source.CandidatePaths(), ", "))
+	}
+	return roots, nil
+}
+
+// resolveApiKey 按 参数 > 环境变量 > 已保存配置 的顺序确定 API Key，新指定的 Key 会先验证再保存
+// 都未设置时返回空字符串，此时只保存到本地归档
+func resolveApiKey(flagKey string, configManager *storage.ConfigManager) (string, error) {
+	key := flagKey
+	if key == "" {
+		key = os.Getenv("CURSOR_HISTORY_API_KEY")
+	}
+
+	if key == "" {
+		saved, err := configManager.LoadApiKey()
+		if err != nil {
+			return "", err
+		}
+		return saved, nil
+	}
+
+	if err := upload.ValidateApiKey(key, app.Config.ServerURL); err != nil {
+		return "", fmt.Errorf("API Key 验证失败: %v", err)
+	}
+	if err := configManager.SaveApiKey(key); err != nil {
+		return "", err
+	}
+	return key, nil
+}
@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"cursor_history/internal/export"
+	"cursor_history/internal/logging"
+	"cursor_history/internal/storage"
+	"cursor_history/internal/upload"
+)
+
+// 导出的提示词来源
+const (
+	exportFromArchive   = "archive"   // 本地归档
+	exportFromWorkspace = "workspace" // 直接读取工作区的 state.vscdb
+)
+
+// runExport 导出提示词，按工作区和日期分组输出为 Markdown、JSON、JSONL、CSV 或 HTML
+func runExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	dbPath := fs.String("db", "", "配置数据库路径，-from archive 时也可以是 sqlite 上传目标的归档库（默认为用户配置目录下的 CursorHistory/config.db）")
+	from := fs.String("from", exportFromArchive, "提示词来源: archive（本地归档）、workspace（直接读取工作区的 state.vscdb）")
+	dir := fs.String("dir", "", "-from workspace 时读取的 workspaceStorage 目录，多个目录用系统路径分隔符分隔（默认自动查找）")
+	format := fs.String("format", "", "导出格式: "+strings.Join(export.Formats(), "、")+"（默认按输出文件扩展名判断，否则为 markdown）")
+	output := fs.String("o", "", "输出文件路径（默认输出到标准输出）")
+	title := fs.String("title", export.DefaultTitle, "Markdown 和 HTML 的标题")
+	workspace := fs.String("workspace", "", "只导出工作区路径包含该字符串的提示词")
+	editor := fs.String("editor", "", "只导出指定编辑器的提示词，如 cursor、windsurf")
+	commandTypes := fs.String("type", "", "只导出指定命令类型的提示词，多个类型用逗号分隔")
+	since := fs.String("since", "", "起始时间，如 2024-01-31、720h、7d")
+	until := fs.String("until", "", "结束日期 (YYYY-MM-DD，包含当天)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: cursor_history export [参数]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if *from != exportFromArchive && *from != exportFromWorkspace {
+		fmt.Fprintf(os.Stderr, "无效的提示词来源: %s，可用: %s、%s\n", *from, exportFromArchive, exportFromWorkspace)
+		return 2
+	}
+	if *format == "" {
+		*format = export.FormatMarkdown
+		if inferred, ok := export.FormatForPath(*output); ok {
+			*format = inferred
+		}
+	}
+	if err := export.ValidateFormat(*format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	filter := storage.SearchFilter{
+		Workspace: *workspace,
+		Editor:    *editor,
+		Limit:     -1,
+	}
+
+	var err error
+	if filter.CommandTypes, err = parseInts(*commandTypes); err != nil {
+		fmt.Fprintf(os.Stderr, "无效的命令类型: %v\n", err)
+		return 2
+	}
+	if *since != "" {
+		if filter.Since, err = storage.ParseSince(*since, time.Now()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	if filter.Until, err = parseDate(*until); err != nil {
+		fmt.Fprintf(os.Stderr, "无效的结束日期: %v\n", err)
+		return 2
+	}
+	if !filter.Until.IsZero() {
+		filter.Until = filter.Until.AddDate(0, 0, 1)
+	}
+
+	var prompts []storage.ArchivedPrompt
+	if *from == exportFromWorkspace {
+		prompts, err = readWorkspacePrompts(*dbPath, *dir, filter)
+	} else {
+		prompts, err = searchArchive(*dbPath, filter)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	opts := export.Options{Title: *title}
+	if *output == "" {
+		if err := export.Write(os.Stdout, *format, prompts, opts); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	if err := writeExportFile(*output, *format, prompts, opts); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "已导出 %d 条提示词到 %s\n", len(prompts), *output)
+	return 0
+}
+
+// searchArchive 从本地归档中查询满足过滤条件的提示词
+func searchArchive(dbPath string, filter storage.SearchFilter) ([]storage.ArchivedPrompt, error) {
+	archive, err := openArchive(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+	return archive.Search("", filter)
+}
+
+// readWorkspacePrompts 直接读取工作区 state.vscdb 中满足过滤条件的提示词，读取方式和脱敏规则使用配置数据库中的配置
+func readWorkspacePrompts(dbPath, dirs string, filter storage.SearchFilter) ([]storage.ArchivedPrompt, error) {
+	configManager, err := openConfigManager(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer configManager.Close()
+
+	roots, err := resolveRoots(dirs, configManager)
+	if err != nil {
+		return nil, err
+	}
+	// 标准输出可能用于输出导出内容，日志输出到标准错误
+	return upload.ReadPrompts(roots, filter, configManager, logging.NewStderrLogger()), nil
+}
+
+// writeExportFile 导出到文件，失败时删除未写完的文件
+func writeExportFile(path, format string, prompts []storage.ArchivedPrompt, opts export.Options) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("创建导出文件失败: %v", err)
+	}
+
+	err = export.Write(file, format, prompts, opts)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("写入导出文件失败: %v", closeErr)
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runOutbox 查看上传队列状态，或把失败的记录重新加入队列
+func runOutbox(args []string) int {
+	fs := flag.NewFlagSet("outbox", flag.ContinueOnError)
+	dbPath := fs.String("db", "", "配置数据库路径（默认为用户配置目录下的 CursorHistory/config.db）")
+	retry := fs.Bool("retry", false, "把所有失败的记录重新加入队列")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	configManager, err := openConfigManager(*dbPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer configManager.Close()
+
+	if *retry {
+		count, err := configManager.RetryFailedOutbox()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("已重新加入队列: %d 条\n", count)
+	}
+
+	status, err := configManager.GetOutboxStatus()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("待发送: %d\n失败: %d\n已发送: %d\n", status.Pending, status.Failed, status.Delivered)
+	return 0
+}
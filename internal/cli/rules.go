@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"cursor_history/internal/policy"
+	"cursor_history/internal/storage"
+)
+
+// runRules 查看或修改工作区上传规则
+func runRules(args []string) int {
+	fs := flag.NewFlagSet("rules", flag.ContinueOnError)
+	dbPath := fs.String("db", "", "配置数据库路径（默认为用户配置目录下的 CursorHistory/config.db）")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: cursor_history rules [-db 路径] <子命令> [参数]")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "子命令:")
+		fmt.Fprintln(fs.Output(), "  list                                        列出所有规则（默认）")
+		fmt.Fprintln(fs.Output(), "  add <allow|deny> [-workspace 模式] [-remote 模式] [-at 序号]")
+		fmt.Fprintln(fs.Output(), "                                              添加规则，默认追加到末尾")
+		fmt.Fprintln(fs.Output(), "  remove <序号>                               删除规则")
+		fmt.Fprintln(fs.Output(), "  default <allow|deny>                        设置没有规则匹配时的动作")
+		fmt.Fprintln(fs.Output(), "  test -workspace 目录 [-remote 远程地址]      查看工作区是否允许上传")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "规则按顺序匹配，第一条匹配的规则生效。被拒绝的工作区仍会归档到本地，")
+		fmt.Fprintln(fs.Output(), "并只发送到 file、sqlite 等本地上传目标。")
+		fmt.Fprintln(fs.Output(), "模式默认为 glob（* 不跨越 /，** 可跨越 /，忽略大小写），以 re: 开头时为正则表达式。")
+		fmt.Fprintln(fs.Output())
+		fmt.Fprintln(fs.Output(), "参数:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	configManager, err := openConfigManager(*dbPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer configManager.Close()
+
+	value, err := configManager.LoadSetting(storage.SettingWorkspaceRules)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	config, err := policy.Parse(value)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	rest := fs.Args()
+	if len(rest) == 0 {
+		rest = []string{"list"}
+	}
+
+	switch rest[0] {
+	case "list":
+		printRules(config)
+		return 0
+	case "add":
+		if code := addRule(&config, rest[1:]); code != 0 {
+			return code
+		}
+	case "remove":
+		if len(rest) != 2 {
+			fs.Usage()
+			return 2
+		}
+		n, err := strconv.Atoi(rest[1])
+		if err != nil || n < 1 || n > len(config.Rules) {
+			fmt.Fprintf(os.Stderr, "无效的规则序号: %s\n", rest[1])
+			return 2
+		}
+		config.Rules = append(config.Rules[:n-1], config.Rules[n:]...)
+	case "default":
+		if len(rest) != 2 {
+			fs.Usage()
+			return 2
+		}
+		config.Default = rest[1]
+	case "test":
+		return testRules(config, rest[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "未知子命令: %s\n\n", rest[0])
+		fs.Usage()
+		return 2
+	}
+
+	if _, err := policy.Compile(config); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := configManager.SaveSetting(storage.SettingWorkspaceRules, string(data)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	printRules(config)
+	return 0
+}
+
+// addRule 解析 add 子命令参数并插入规则
+func addRule(config *policy.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "用法: cursor_history rules add <allow|deny> [-workspace 模式] [-remote 模式] [-at 序号]")
+		return 2
+	}
+
+	fs := flag.NewFlagSet("rules add", flag.ContinueOnError)
+	workspace := fs.String("workspace", "", "匹配工作区目录的模式")
+	remote := fs.String("remote", "", "匹配 Git 远程地址的模式")
+	at := fs.Int("at", 0, "插入位置（从 1 开始），默认追加到末尾")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	rule := policy.Rule{Action: args[0], Workspace: *workspace, Remote: *remote}
+	index := len(config.Rules)
+	if *at > 0 && *at <= len(config.Rules) {
+		index = *at - 1
+	}
+	config.Rules = append(config.Rules, policy.Rule{})
+	copy(config.Rules[index+1:], config.Rules[index:])
+	config.Rules[index] = rule
+	return 0
+}
+
+// testRules 输出工作区的判定结果
+func testRules(config policy.Config, args []string) int {
+	fs := flag.NewFlagSet("rules test", flag.ContinueOnError)
+	workspace := fs.String("workspace", "", "工作区目录")
+	remote := fs.String("remote", "", "Git 远程地址")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	p, err := policy.Compile(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	decision := p.Evaluate(*workspace, *remote)
+	result := "拒绝上传（只保存到本地）"
+	if decision.Allowed {
+		result = "允许上传"
+	}
+	if decision.Rule > 0 {
+		fmt.Printf("%s，命中第 %d 条规则: %s\n", result, decision.Rule, config.Rules[decision.Rule-1])
+	} else {
+		fmt.Printf("%s，未匹配任何规则，使用默认动作\n", result)
+	}
+	return 0
+}
+
+// printRules 输出规则列表
+func printRules(config policy.Config) {
+	defaultAction := config.Default
+	if defaultAction == "" {
+		defaultAction = policy.ActionAllow
+	}
+	if len(config.Rules) == 0 {
+		fmt.Println("未配置规则")
+	}
+	for i, rule := range config.Rules {
+		fmt.Printf("%3d  %s\n", i+1, rule)
+	}
+	fmt.Printf("默认: %s\n", defaultAction)
+}
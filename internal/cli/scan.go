@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"cursor_history/internal/app"
+	"cursor_history/internal/logging"
+	"cursor_history/internal/storage"
+	"cursor_history/internal/types"
+	"cursor_history/internal/upload"
+)
+
+// runScan 补扫已有的工作区数据库，上传监控未运行期间或安装之前的提示词
+func runScan(args []string) int {
+	fs := flag.NewFlagSet("scan", flag.ContinueOnError)
+	env := fs.String("env", "prod", "运行环境 (prod/dev)，可被 CURSOR_ENV 环境变量覆盖")
+	apiKey := fs.String("api-key", "", "API Key，指定后会验证并保存到配置中；也可通过 CURSOR_HISTORY_API_KEY 设置")
+	dbPath := fs.String("db", "", "配置数据库路径（默认为用户配置目录下的 CursorHistory/config.db）")
+	dir := fs.String("dir", "", "要补扫的 workspaceStorage 目录，多个目录用系统路径分隔符分隔（默认自动查找）")
+	since := fs.String("since", "", "只处理该时间之后修改过的数据库，如 2024-01-31、720h、30d")
+	full := fs.Bool("full", false, "忽略上次处理的状态，重新提取全部提示词（已上传的不会重复上传）")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	opts := upload.ScanOptions{Full: *full}
+	if *since != "" {
+		t, err := storage.ParseSince(*since, time.Now())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		opts.Since = t
+	}
+
+	logger, err := logging.NewConsoleLogger("")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	app.Config.SetLogger(logger)
+	defer app.Config.Stop()
+
+	app.InitApp(*env)
+
+	configManager, err := openConfigManager(*dbPath)
+	if err != nil {
+		logger.Log(types.LogLevelError, "初始化配置管理器失败: %v", err)
+		return 1
+	}
+	defer configManager.Close()
+
+	key, err := resolveApiKey(*apiKey, configManager)
+	if err != nil {
+		logger.Log(types.LogLevelError, "%v", err)
+		return 1
+	}
+	app.Config.ApiKey = key
+
+	roots, err := resolveRoots(*dir, configManager)
+	if err != nil {
+		logger.Log(types.LogLevelError, "%v", err)
+		return 1
+	}
+
+	// 收到退出信号后在当前数据库处理完后停止
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case <-signals:
+			configManager.Cancel()
+		case <-configManager.GetContext().Done():
+		}
+	}()
+
+	opts.Progress = func(p upload.ScanProgress) {
+		fmt.Fprintf(os.Stderr, "[%d/%d] %s\n", p.Done, p.Total, p.Path)
+	}
+	result, err := upload.Scan(configManager.GetContext(), roots, opts, configManager, logger)
+	if err != nil {
+		logger.Log(types.LogLevelWarning, "补扫已中断: %s", result)
+		return 1
+	}
+	logger.Log(types.LogLevelSuccess, "补扫完成: %s", result)
+	return 0
+}
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"cursor_history/internal/storage"
+)
+
+// runSearch 搜索本地归档的提示词
+func runSearch(args []string) int {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	dbPath := fs.String("db", "", "配置数据库或 sqlite 上传目标的归档库路径（默认为用户配置目录下的 CursorHistory/config.db）")
+	workspace := fs.String("workspace", "", "只显示工作区路径包含该字符串的提示词")
+	editor := fs.String("editor", "", "只显示指定编辑器的提示词，如 cursor、windsurf")
+	commandTypes := fs.String("type", "", "只显示指定命令类型的提示词，多个类型用逗号分隔")
+	since := fs.String("since", "", "起始日期 (YYYY-MM-DD)")
+	until := fs.String("until", "", "结束日期 (YYYY-MM-DD，包含当天)")
+	limit := fs.Int("limit", 50, "最多显示条数")
+	asJSON := fs.Bool("json", false, "以 JSON 格式输出")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: cursor_history search [参数] [关键词...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	filter := storage.SearchFilter{
+		Workspace: *workspace,
+		Editor:    *editor,
+		Limit:     *limit,
+	}
+
+	var err error
+	if filter.CommandTypes, err = parseInts(*commandTypes); err != nil {
+		fmt.Fprintf(os.Stderr, "无效的命令类型: %v\n", err)
+		return 2
+	}
+	if filter.Since, err = parseDate(*since); err != nil {
+		fmt.Fprintf(os.Stderr, "无效的起始日期: %v\n", err)
+		return 2
+	}
+	if filter.Until, err = parseDate(*until); err != nil {
+		fmt.Fprintf(os.Stderr, "无效的结束日期: %v\n", err)
+		return 2
+	}
+	if !filter.Until.IsZero() {
+		filter.Until = filter.Until.AddDate(0, 0, 1)
+	}
+
+	archive, err := openArchive(*dbPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer archive.Close()
+	if !archive.FullTextSearch() {
+		fmt.Fprintln(os.Stderr, "当前程序编译时未启用 sqlite_fts5，搜索不使用全文索引，改为逐条 LIKE 匹配")
+	}
+
+	results, err := archive.Search(strings.Join(fs.Args(), " "), filter)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	for _, p := range results {
+		fmt.Printf("[%s] %s  %s  (type %d)\n", time.Unix(p.Timestamp, 0).Format("2006-01-02 15:04"), p.Editor, p.Workspace, p.CommandType)
+		for _, line := range strings.Split(p.Text, "\n") {
+			fmt.Printf("    %s\n", line)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("共 %d 条结果\n", len(results))
+	return 0
+}
+
+// openArchive 打开提示词归档，dbPath 为空时使用配置数据库中的归档
+// 只读写归档表，因此也可以打开 sqlite 上传目标的独立归档库
+func openArchive(dbPath string) (*storage.Archive, error) {
+	if dbPath == "" {
+		var err error
+		dbPath, err = storage.DefaultDBPath()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return storage.OpenArchive(dbPath)
+}
+
+// parseDate 解析 YYYY-MM-DD 格式的本地日期，空字符串返回零值
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation("2006-01-02", s, time.Local)
+}
+
+// parseInts 解析逗号分隔的整数列表
+func parseInts(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	var values []int
+	for _, part := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, n)
+	}
+	return values, nil
+}
@@ -0,0 +1,98 @@
+package control
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"cursor_history/internal/logging"
+	"cursor_history/internal/types"
+)
+
+// LogBufferSize 控制接口保留的最近日志条数
+const LogBufferSize = 1000
+
+// LogBuffer 保存最近的日志供控制接口查询，实现 types.Logger 和 logging.EntryLogger 接口
+type LogBuffer struct {
+	mu        sync.Mutex
+	entries   []logging.Entry // 环形缓冲区
+	next      int             // 下一条写入的位置
+	full      bool
+	lastError *logging.Entry
+}
+
+// NewLogBuffer 创建最多保存 size 条日志的缓冲区
+func NewLogBuffer(size int) *LogBuffer {
+	if size < 1 {
+		size = 1
+	}
+	return &LogBuffer{entries: make([]logging.Entry, size)}
+}
+
+// Log 记录日志
+func (b *LogBuffer) Log(level string, format string, args ...interface{}) {
+	b.LogEntry(logging.Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// LogEntry 记录一条结构化日志，ERROR 级别的日志同时记为最近一次错误
+func (b *LogBuffer) LogEntry(entry logging.Entry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.entries[b.next] = entry
+	b.next = (b.next + 1) % len(b.entries)
+	if b.next == 0 {
+		b.full = true
+	}
+	if entry.Level == types.LogLevelError {
+		b.lastError = &entry
+	}
+}
+
+// Close 实现 types.Logger 接口，缓冲区没有需要释放的资源
+func (b *LogBuffer) Close() error {
+	return nil
+}
+
+// Recent 按时间顺序返回最近最多 limit 条不低于 minLevel 的日志，component 不为空时只返回该组件的日志
+func (b *LogBuffer) Recent(limit int, minLevel logging.Level, component string) []logging.Entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ordered := b.entries[:b.next]
+	if b.full {
+		ordered = append(append([]logging.Entry(nil), b.entries[b.next:]...), b.entries[:b.next]...)
+	}
+
+	// 从最新的日志往前取，再恢复时间顺序
+	var result []logging.Entry
+	for i := len(ordered) - 1; i >= 0 && len(result) < limit; i-- {
+		entry := ordered[i]
+		if logging.LevelOf(entry.Level) < minLevel {
+			continue
+		}
+		if component != "" && entry.Component != component {
+			continue
+		}
+		result = append(result, entry)
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+// LastError 返回最近一次 ERROR 级别的日志
+func (b *LogBuffer) LastError() (logging.Entry, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.lastError == nil {
+		return logging.Entry{}, false
+	}
+	return *b.lastError, true
+}
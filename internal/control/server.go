@@ -0,0 +1,406 @@
+package control
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cursor_history/internal/app"
+	"cursor_history/internal/logging"
+	"cursor_history/internal/storage"
+	"cursor_history/internal/types"
+	"cursor_history/internal/upload"
+)
+
+// TokenFile 控制接口令牌文件名，位于配置数据库所在目录
+const TokenFile = "control.token"
+
+// LogComponent 控制接口的日志组件名
+const LogComponent = "control"
+
+// 日志查询默认和最多返回的条数
+const (
+	defaultLogLimit = 100
+	maxLogLimit     = 1000
+)
+
+// Monitor 控制接口开始和停止监控的方式，由守护进程和 GUI 分别实现
+type Monitor interface {
+	StartMonitoring() error
+	StopMonitoring() error
+}
+
+// Options 控制接口参数
+type Options struct {
+	Addr          string // 监听地址，只允许本机回环地址
+	ConfigManager *storage.ConfigManager
+	Monitor       Monitor
+	Logs          *LogBuffer // 最近的日志，为空时日志接口返回空列表
+	Logger        types.Logger
+}
+
+// Server 本机 HTTP 控制接口，所有请求都需要 Authorization: Bearer <令牌>
+type Server struct {
+	opts      Options
+	logger    *logging.Logger
+	token     string
+	tokenPath string
+	listener  net.Listener
+	server    *http.Server
+
+	scanMu   sync.Mutex
+	scanning bool
+	lastScan *scanReport
+}
+
+// scanReport 最近一次通过控制接口触发的补扫结果
+type scanReport struct {
+	Found      int       `json:"found"`
+	Scanned    int       `json:"scanned"`
+	Skipped    int       `json:"skipped"`
+	Enqueued   int       `json:"enqueued"`
+	Error      string    `json:"error,omitempty"`
+	FinishedAt time.Time `json:"finishedAt"`
+}
+
+// ValidateAddr 校验监听地址，只允许 localhost 或回环 IP，避免控制接口暴露到网络上
+func ValidateAddr(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("无效的监听地址 %s: %v", addr, err)
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return fmt.Errorf("无效的监听端口: %s", port)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("控制接口只能监听本机回环地址（如 127.0.0.1:7611），当前为: %s", addr)
+}
+
+// Start 生成新的令牌写入配置目录并开始监听，令牌文件在 Close 时删除
+func Start(opts Options) (*Server, error) {
+	if err := ValidateAddr(opts.Addr); err != nil {
+		return nil, err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", opts.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("控制接口监听失败: %v", err)
+	}
+
+	s := &Server{
+		opts:      opts,
+		logger:    logging.From(opts.Logger).Component(LogComponent),
+		token:     token,
+		tokenPath: filepath.Join(opts.ConfigManager.Dir(), TokenFile),
+		listener:  listener,
+	}
+	if err := os.WriteFile(s.tokenPath, []byte(token+"\n"), 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("保存控制接口令牌失败: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/status", s.handle(http.MethodGet, s.handleStatus))
+	mux.HandleFunc("/api/monitor/start", s.handle(http.MethodPost, s.handleStart))
+	mux.HandleFunc("/api/monitor/stop", s.handle(http.MethodPost, s.handleStop))
+	mux.HandleFunc("/api/rescan", s.handle(http.MethodPost, s.handleRescan))
+	mux.HandleFunc("/api/logs", s.handle(http.MethodGet, s.handleLogs))
+	s.server = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("控制接口停止", "error", err)
+		}
+	}()
+	s.logger.Info("控制接口已启动", "url", "http://"+listener.Addr().String(), "tokenFile", s.tokenPath)
+	return s, nil
+}
+
+// Addr 返回实际监听的地址
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close 停止控制接口并删除令牌文件
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := s.server.Shutdown(ctx)
+	if removeErr := os.Remove(s.tokenPath); removeErr != nil && !os.IsNotExist(removeErr) && err == nil {
+		err = fmt.Errorf("删除控制接口令牌失败: %v", removeErr)
+	}
+	return err
+}
+
+// newToken 生成 32 字节的随机令牌
+func newToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成控制接口令牌失败: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// handle 校验请求方法和令牌后调用 handler
+func (s *Server) handle(method string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "令牌无效")
+			return
+		}
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeError(w, http.StatusMethodNotAllowed, "只支持 "+method+" 请求")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// statusResponse 状态接口的响应
+type statusResponse struct {
+	Version    string               `json:"version"`
+	Monitoring bool                 `json:"monitoring"`
+	StartedAt  *time.Time           `json:"startedAt,omitempty"`
+	Roots      []rootInfo           `json:"roots"`
+	Outbox     storage.OutboxStatus `json:"outbox"`
+	LastUpload *time.Time           `json:"lastUpload,omitempty"`
+	LastError  *logLine             `json:"lastError,omitempty"`
+	Scanning   bool                 `json:"scanning"`
+	LastScan   *scanReport          `json:"lastScan,omitempty"`
+}
+
+// rootInfo 监控的 workspaceStorage 目录
+type rootInfo struct {
+	Path   string `json:"path"`
+	Source string `json:"source"`
+}
+
+// logLine 日志接口返回的一条日志
+type logLine struct {
+	Time      time.Time              `json:"time"`
+	Level     string                 `json:"level"`
+	Component string                 `json:"component,omitempty"`
+	Message   string                 `json:"msg"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+}
+
+// newLogLine 把日志条目转换为接口返回的格式
+func newLogLine(entry logging.Entry) logLine {
+	return logLine{
+		Time:      entry.Time,
+		Level:     entry.Level,
+		Component: entry.Component,
+		Message:   entry.Message,
+		Fields:    entry.Fields,
+	}
+}
+
+// handleStatus 返回监控状态、上传队列和最近一次上传、错误和补扫的情况
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	status := upload.CurrentStatus()
+	resp := statusResponse{
+		Version:    app.Version,
+		Monitoring: status.Monitoring,
+		Roots:      []rootInfo{},
+	}
+	if status.Monitoring {
+		resp.StartedAt = &status.StartedAt
+	}
+	for _, root := range status.Roots {
+		resp.Roots = append(resp.Roots, rootInfo{Path: root.Path, Source: root.Source.Name})
+	}
+
+	outbox, err := s.opts.ConfigManager.GetOutboxStatus()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	resp.Outbox = outbox
+
+	last, ok, err := s.opts.ConfigManager.LastOutboxDelivery()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if ok {
+		resp.LastUpload = &last
+	}
+
+	if s.opts.Logs != nil {
+		if entry, ok := s.opts.Logs.LastError(); ok {
+			line := newLogLine(entry)
+			resp.LastError = &line
+		}
+	}
+
+	s.scanMu.Lock()
+	resp.Scanning = s.scanning
+	resp.LastScan = s.lastScan
+	s.scanMu.Unlock()
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleStart 开始监控
+func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
+	if upload.CurrentStatus().Monitoring {
+		writeError(w, http.StatusConflict, "监控已在运行")
+		return
+	}
+	if err := s.opts.Monitor.StartMonitoring(); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	s.logger.Info("通过控制接口开始监控")
+	writeJSON(w, http.StatusOK, map[string]bool{"monitoring": true})
+}
+
+// handleStop 停止监控
+func (s *Server) handleStop(w http.ResponseWriter, r *http.Request) {
+	if !upload.CurrentStatus().Monitoring {
+		writeError(w, http.StatusConflict, upload.ErrNotMonitoring.Error())
+		return
+	}
+	if err := s.opts.Monitor.StopMonitoring(); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	s.logger.Info("通过控制接口停止监控")
+	writeJSON(w, http.StatusOK, map[string]bool{"monitoring": false})
+}
+
+// rescanRequest 补扫请求，请求体可为空
+type rescanRequest struct {
+	Since string `json:"since"` // 只处理该时间之后修改过的数据库，格式同 scan -since
+	Full  bool   `json:"full"`  // 忽略上次处理的状态，重新提取全部提示词
+}
+
+// handleRescan 在运行中的监控里开始补扫，立即返回，结果通过状态接口查询
+func (s *Server) handleRescan(w http.ResponseWriter, r *http.Request) {
+	var req rescanRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("解析请求失败: %v", err))
+			return
+		}
+	}
+
+	opts := upload.ScanOptions{Full: req.Full}
+	if req.Since != "" {
+		since, err := storage.ParseSince(req.Since, time.Now())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		opts.Since = since
+	}
+
+	if !upload.CurrentStatus().Monitoring {
+		writeError(w, http.StatusConflict, upload.ErrNotMonitoring.Error())
+		return
+	}
+	s.scanMu.Lock()
+	if s.scanning {
+		s.scanMu.Unlock()
+		writeError(w, http.StatusConflict, "补扫正在进行")
+		return
+	}
+	s.scanning = true
+	s.scanMu.Unlock()
+
+	s.logger.Info("通过控制接口开始补扫", "since", req.Since, "full", req.Full)
+	go func() {
+		result, err := upload.Rescan(opts)
+		report := &scanReport{
+			Found:      result.Found,
+			Scanned:    result.Scanned,
+			Skipped:    result.Skipped,
+			Enqueued:   result.Enqueued,
+			FinishedAt: time.Now(),
+		}
+		if err != nil {
+			report.Error = err.Error()
+			s.logger.Warn("补扫未完成", "error", err, "result", result.String())
+		} else {
+			s.logger.Info("补扫完成", "result", result.String())
+		}
+
+		s.scanMu.Lock()
+		s.scanning = false
+		s.lastScan = report
+		s.scanMu.Unlock()
+	}()
+	writeJSON(w, http.StatusAccepted, map[string]bool{"scanning": true})
+}
+
+// handleLogs 返回最近的日志，支持 limit、level（最低级别）和 component 参数
+func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := defaultLogLimit
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "无效的 limit: "+value)
+			return
+		}
+		if limit = n; limit > maxLogLimit {
+			limit = maxLogLimit
+		}
+	}
+
+	minLevel := logging.LevelDebug
+	if value := query.Get("level"); value != "" {
+		level, err := logging.ParseLevel(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		minLevel = level
+	}
+
+	lines := []logLine{}
+	if s.opts.Logs != nil {
+		for _, entry := range s.opts.Logs.Recent(limit, minLevel, query.Get("component")) {
+			lines = append(lines, newLogLine(entry))
+		}
+	}
+	writeJSON(w, http.StatusOK, lines)
+}
+
+// writeJSON 以 JSON 格式写入响应
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError 以 {"error": "..."} 格式写入错误响应
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]string{"error": message})
+}
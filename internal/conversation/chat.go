@@ -0,0 +1,67 @@
+package conversation
+
+import "encoding/json"
+
+// ChatDataKey 聊天面板数据在工作区 ItemTable 中的键
+const ChatDataKey = "workbench.panel.aichat.view.aichat.chatdata"
+
+// ParseChatData 解析聊天面板数据，每个标签页对应一个对话
+// 格式为 {"tabs": [{"tabId": "...", "chatTitle": "...", "bubbles": [{"type": "user"|"ai", "text": "..."}]}]}
+func ParseChatData(value string) ([]Conversation, error) {
+	var data struct {
+		Tabs []struct {
+			TabID        string `json:"tabId"`
+			ChatTitle    string `json:"chatTitle"`
+			LastSendTime millis `json:"lastSendTime"`
+			Bubbles      []struct {
+				ID             string    `json:"id"`
+				Type           string    `json:"type"`
+				Text           string    `json:"text"`
+				RawText        string    `json:"rawText"`
+				Timestamp      millis    `json:"timestamp"`
+				Selections     []fileRef `json:"selections"`
+				FileSelections []fileRef `json:"fileSelections"`
+			} `json:"bubbles"`
+		} `json:"tabs"`
+	}
+	if err := json.Unmarshal([]byte(value), &data); err != nil {
+		return nil, err
+	}
+
+	conversations := make([]Conversation, 0, len(data.Tabs))
+	for _, tab := range data.Tabs {
+		conv := Conversation{
+			ID:        tab.TabID,
+			Kind:      KindChat,
+			Title:     tab.ChatTitle,
+			UpdatedAt: int64(tab.LastSendTime),
+		}
+
+		for _, bubble := range tab.Bubbles {
+			text := bubble.Text
+			if text == "" {
+				text = bubble.RawText
+			}
+			if text == "" {
+				continue
+			}
+
+			role := RoleAssistant
+			if bubble.Type == "user" {
+				role = RoleUser
+			}
+			conv.Messages = append(conv.Messages, Message{
+				ID:        bubble.ID,
+				Role:      role,
+				Text:      text,
+				Timestamp: int64(bubble.Timestamp),
+				Files:     collectFiles(bubble.Selections, bubble.FileSelections),
+			})
+		}
+
+		if len(conv.Messages) > 0 {
+			conversations = append(conversations, conv)
+		}
+	}
+	return conversations, nil
+}
@@ -0,0 +1,196 @@
+package conversation
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Composer 相关的键
+const (
+	// ComposerDataKey 工作区 ItemTable 中的 Composer 索引
+	ComposerDataKey = "composer.composerData"
+	// ComposerKeyPrefix 全局 cursorDiskKV 表中 Composer 会话的键前缀，完整键为 composerData:<composerId>
+	ComposerKeyPrefix = "composerData:"
+	// BubbleKeyPrefix 全局 cursorDiskKV 表中单条气泡的键前缀，完整键为 bubbleId:<composerId>:<bubbleId>
+	BubbleKeyPrefix = "bubbleId:"
+)
+
+// Composer 气泡类型
+const (
+	bubbleTypeUser      = 1
+	bubbleTypeAssistant = 2
+)
+
+// Lookup 在全局 cursorDiskKV 表中按键查找值
+type Lookup func(key string) (string, bool)
+
+// ComposerRef 工作区 Composer 索引中的一项
+type ComposerRef struct {
+	ID            string
+	Name          string
+	CreatedAt     int64
+	LastUpdatedAt int64
+}
+
+// ParseComposerIndex 解析工作区中的 composer.composerData，返回该工作区拥有的 Composer 会话
+func ParseComposerIndex(value string) ([]ComposerRef, error) {
+	var data struct {
+		AllComposers []struct {
+			ComposerID    string `json:"composerId"`
+			Name          string `json:"name"`
+			CreatedAt     millis `json:"createdAt"`
+			LastUpdatedAt millis `json:"lastUpdatedAt"`
+		} `json:"allComposers"`
+	}
+	if err := json.Unmarshal([]byte(value), &data); err != nil {
+		return nil, err
+	}
+
+	refs := make([]ComposerRef, 0, len(data.AllComposers))
+	for _, c := range data.AllComposers {
+		if c.ComposerID == "" {
+			continue
+		}
+		refs = append(refs, ComposerRef{
+			ID:            c.ComposerID,
+			Name:          c.Name,
+			CreatedAt:     int64(c.CreatedAt),
+			LastUpdatedAt: int64(c.LastUpdatedAt),
+		})
+	}
+	return refs, nil
+}
+
+// composerBubble Composer 中的一条气泡
+type composerBubble struct {
+	BubbleID  string `json:"bubbleId"`
+	Type      int    `json:"type"`
+	Text      string `json:"text"`
+	CreatedAt millis `json:"createdAt"`
+	Context   struct {
+		FileSelections []fileRef `json:"fileSelections"`
+	} `json:"context"`
+	RelevantFiles []string `json:"relevantFiles"`
+	TimingInfo    struct {
+		ClientStartTime millis `json:"clientStartTime"`
+	} `json:"timingInfo"`
+}
+
+func (b composerBubble) message() (Message, bool) {
+	if b.Text == "" {
+		return Message{}, false
+	}
+
+	role := RoleAssistant
+	if b.Type == bubbleTypeUser {
+		role = RoleUser
+	} else if b.Type != bubbleTypeAssistant {
+		return Message{}, false
+	}
+
+	timestamp := int64(b.CreatedAt)
+	if timestamp == 0 {
+		timestamp = int64(b.TimingInfo.ClientStartTime)
+	}
+
+	refs := make([]fileRef, 0, len(b.RelevantFiles))
+	for _, path := range b.RelevantFiles {
+		refs = append(refs, fileRef{Path: path})
+	}
+
+	return Message{
+		ID:        b.BubbleID,
+		Role:      role,
+		Text:      b.Text,
+		Timestamp: timestamp,
+		Files:     collectFiles(b.Context.FileSelections, refs),
+	}, true
+}
+
+// ParseComposerData 解析全局 cursorDiskKV 中的 composerData:<id>
+// 旧版本气泡直接保存在 conversation 字段中，新版本只保存 fullConversationHeadersOnly，
+// 气泡内容需要通过 lookup 读取 bubbleId:<composerId>:<bubbleId>
+func ParseComposerData(value string, lookup Lookup) (Conversation, error) {
+	var data struct {
+		ComposerID    string           `json:"composerId"`
+		Name          string           `json:"name"`
+		CreatedAt     millis           `json:"createdAt"`
+		LastUpdatedAt millis           `json:"lastUpdatedAt"`
+		Conversation  []composerBubble `json:"conversation"`
+		Headers       []struct {
+			BubbleID string `json:"bubbleId"`
+		} `json:"fullConversationHeadersOnly"`
+	}
+	if err := json.Unmarshal([]byte(value), &data); err != nil {
+		return Conversation{}, err
+	}
+
+	conv := Conversation{
+		ID:        data.ComposerID,
+		Kind:      KindComposer,
+		Title:     data.Name,
+		CreatedAt: int64(data.CreatedAt),
+		UpdatedAt: int64(data.LastUpdatedAt),
+	}
+
+	bubbles := data.Conversation
+	if len(bubbles) == 0 && lookup != nil {
+		for _, header := range data.Headers {
+			raw, ok := lookup(fmt.Sprintf("%s%s:%s", BubbleKeyPrefix, data.ComposerID, header.BubbleID))
+			if !ok {
+				continue
+			}
+			var bubble composerBubble
+			if err := json.Unmarshal([]byte(raw), &bubble); err != nil {
+				continue
+			}
+			bubbles = append(bubbles, bubble)
+		}
+	}
+
+	for _, bubble := range bubbles {
+		if msg, ok := bubble.message(); ok {
+			conv.Messages = append(conv.Messages, msg)
+		}
+	}
+	return conv, nil
+}
+
+// ParseComposers 解析工作区 Composer 索引，并从全局 cursorDiskKV 读取每个会话的完整内容
+func ParseComposers(value string, lookup Lookup) ([]Conversation, error) {
+	refs, err := ParseComposerIndex(value)
+	if err != nil {
+		return nil, err
+	}
+	if lookup == nil {
+		return nil, nil
+	}
+
+	var conversations []Conversation
+	for _, ref := range refs {
+		raw, ok := lookup(ComposerKeyPrefix + ref.ID)
+		if !ok {
+			continue
+		}
+		conv, err := ParseComposerData(raw, lookup)
+		if err != nil {
+			return nil, fmt.Errorf("解析 Composer %s 失败: %v", ref.ID, err)
+		}
+		if conv.ID == "" {
+			conv.ID = ref.ID
+		}
+		if conv.Title == "" {
+			conv.Title = ref.Name
+		}
+		if conv.CreatedAt == 0 {
+			conv.CreatedAt = ref.CreatedAt
+		}
+		if conv.UpdatedAt == 0 {
+			conv.UpdatedAt = ref.LastUpdatedAt
+		}
+		if len(conv.Messages) > 0 {
+			conversations = append(conversations, conv)
+		}
+	}
+	return conversations, nil
+}
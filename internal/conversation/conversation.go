@@ -0,0 +1,116 @@
+package conversation
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Role 消息角色
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+)
+
+// 对话类型
+const (
+	KindChat     = "chat"
+	KindComposer = "composer"
+)
+
+// Message 对话中的一条消息（气泡）
+type Message struct {
+	ID        string   `json:"id"`
+	Role      Role     `json:"role"`
+	Text      string   `json:"text"`
+	Timestamp int64    `json:"timestamp,omitempty"` // 毫秒时间戳，未知时为 0
+	Files     []string `json:"files,omitempty"`     // 消息引用的文件
+}
+
+// Conversation 一个聊天标签页或 Composer 会话
+type Conversation struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`
+	Title     string    `json:"title"`
+	CreatedAt int64     `json:"createdAt,omitempty"` // 毫秒时间戳
+	UpdatedAt int64     `json:"updatedAt,omitempty"` // 毫秒时间戳
+	Messages  []Message `json:"messages"`
+}
+
+// Files 返回对话中所有消息引用的文件（去重）
+func (c *Conversation) Files() []string {
+	seen := make(map[string]bool)
+	var files []string
+	for _, msg := range c.Messages {
+		for _, file := range msg.Files {
+			if !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// millis 兼容数字（毫秒）和 RFC3339 字符串两种格式的时间字段
+type millis int64
+
+func (m *millis) UnmarshalJSON(data []byte) error {
+	var n float64
+	if err := json.Unmarshal(data, &n); err == nil {
+		*m = millis(n)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil // 无法识别的格式按未知时间处理
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		*m = millis(n)
+		return nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		*m = millis(t.UnixMilli())
+	}
+	return nil
+}
+
+// fileRef 文件引用，不同版本使用 uri.fsPath、uri.path 或 path 字段
+type fileRef struct {
+	URI struct {
+		FsPath string `json:"fsPath"`
+		Path   string `json:"path"`
+	} `json:"uri"`
+	Path string `json:"path"`
+}
+
+func (f fileRef) path() string {
+	switch {
+	case f.URI.FsPath != "":
+		return f.URI.FsPath
+	case f.URI.Path != "":
+		return f.URI.Path
+	default:
+		return f.Path
+	}
+}
+
+// collectFiles 合并多组文件引用并去重
+func collectFiles(groups ...[]fileRef) []string {
+	seen := make(map[string]bool)
+	var files []string
+	for _, refs := range groups {
+		for _, ref := range refs {
+			if p := ref.path(); p != "" && !seen[p] {
+				seen[p] = true
+				files = append(files, p)
+			}
+		}
+	}
+	return files
+}
@@ -0,0 +1,171 @@
+// Package debounce 按键合并短时间内的连续事件，事件停止一段时间后才执行处理
+package debounce
+
+import (
+	"sync"
+	"time"
+)
+
+// Timer 可取消的定时器
+type Timer interface {
+	Stop() bool
+}
+
+// Clock 时钟，测试时可替换为手动推进的假时钟
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// RealClock 使用系统时间的时钟
+type RealClock struct{}
+
+// Now 返回当前时间
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// AfterFunc 在 d 之后在新的协程中执行 f
+func (RealClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// Debouncer 按键合并事件：同一个键的事件在 quiet 时间内没有新事件时才处理，
+// 连续不断的事件最多等待 maxWait 就处理一次；处理期间收到的事件会在处理结束后再触发一次处理，
+// 保证最后一次事件之后一定有一次完整的处理
+type Debouncer struct {
+	clock   Clock
+	quiet   time.Duration
+	maxWait time.Duration
+	process func(key string)
+
+	mu      sync.Mutex
+	states  map[string]*state
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+// state 单个键的等待状态
+type state struct {
+	timer   Timer
+	gen     int       // 定时器代数，用于忽略已被取代的定时器
+	first   time.Time // 本轮第一个事件的时间，零值表示没有等待中的事件
+	running bool      // 是否正在处理
+	pending bool      // 处理期间是否收到了新事件
+}
+
+// New 创建合并器，maxWait 为 0 时不限制最长等待时间
+func New(clock Clock, quiet, maxWait time.Duration, process func(key string)) *Debouncer {
+	if clock == nil {
+		clock = RealClock{}
+	}
+	return &Debouncer{
+		clock:   clock,
+		quiet:   quiet,
+		maxWait: maxWait,
+		process: process,
+		states:  make(map[string]*state),
+	}
+}
+
+// Trigger 记录一个事件
+func (d *Debouncer) Trigger(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stopped {
+		return
+	}
+
+	s := d.states[key]
+	if s == nil {
+		s = &state{}
+		d.states[key] = s
+	}
+	if s.running {
+		s.pending = true
+		return
+	}
+	d.schedule(key, s)
+}
+
+// Pending 返回等待处理或正在处理的键数量
+func (d *Debouncer) Pending() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.states)
+}
+
+// Stop 取消所有等待中的处理，并等待正在进行的处理结束
+func (d *Debouncer) Stop() {
+	d.mu.Lock()
+	d.stopped = true
+	for key, s := range d.states {
+		if s.timer != nil && s.timer.Stop() {
+			d.wg.Done()
+		}
+		s.timer = nil
+		s.gen++
+		if !s.running {
+			delete(d.states, key)
+		}
+	}
+	d.mu.Unlock()
+
+	d.wg.Wait()
+}
+
+// schedule 重新安排键的处理时间，调用时必须持有锁
+func (d *Debouncer) schedule(key string, s *state) {
+	now := d.clock.Now()
+	if s.first.IsZero() {
+		s.first = now
+	}
+
+	delay := d.quiet
+	if d.maxWait > 0 {
+		if remaining := s.first.Add(d.maxWait).Sub(now); remaining < delay {
+			delay = remaining
+		}
+		if delay < 0 {
+			delay = 0
+		}
+	}
+
+	if s.timer != nil && s.timer.Stop() {
+		d.wg.Done()
+	}
+	s.gen++
+	gen := s.gen
+	d.wg.Add(1)
+	s.timer = d.clock.AfterFunc(delay, func() {
+		defer d.wg.Done()
+		d.fire(key, gen)
+	})
+}
+
+// fire 定时器到期后执行处理
+func (d *Debouncer) fire(key string, gen int) {
+	d.mu.Lock()
+	s := d.states[key]
+	if s == nil || s.gen != gen || d.stopped {
+		d.mu.Unlock()
+		return
+	}
+	s.timer = nil
+	s.first = time.Time{}
+	s.running = true
+	s.pending = false
+	d.mu.Unlock()
+
+	d.process(key)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s.running = false
+	if s.pending && !d.stopped {
+		s.pending = false
+		d.schedule(key, s)
+		return
+	}
+	delete(d.states, key)
+}
@@ -0,0 +1,181 @@
+package debounce
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock 手动推进的时钟，到期的定时器在 Advance 的调用协程中按时间顺序执行
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// fakeTimer 假时钟的定时器
+type fakeTimer struct {
+	clock   *fakeClock
+	when    time.Time
+	f       func()
+	stopped bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, when: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	for i, timer := range t.clock.timers {
+		if timer == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			t.stopped = true
+			return true
+		}
+	}
+	return false
+}
+
+// Advance 推进时间，依次执行到期的定时器，执行中新建的到期定时器也会执行
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	for {
+		sort.SliceStable(c.timers, func(i, j int) bool { return c.timers[i].when.Before(c.timers[j].when) })
+		if len(c.timers) == 0 || c.timers[0].when.After(target) {
+			break
+		}
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		c.now = t.when
+		c.mu.Unlock()
+		t.f()
+		c.mu.Lock()
+	}
+	c.now = target
+	c.mu.Unlock()
+}
+
+// counter 记录处理次数
+type counter struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (c *counter) inc() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+}
+
+func (c *counter) get() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}
+
+func checkCalls(t *testing.T, c *counter, want int) {
+	t.Helper()
+	if got := c.get(); got != want {
+		t.Fatalf("处理了 %d 次，期望 %d 次", got, want)
+	}
+}
+
+func TestTriggersWithinQuietProcessOnce(t *testing.T) {
+	clock := newFakeClock()
+	var calls counter
+	d := New(clock, 100*time.Millisecond, time.Second, func(string) { calls.inc() })
+	defer d.Stop()
+
+	for i := 0; i < 5; i++ {
+		d.Trigger("state.vscdb")
+		clock.Advance(50 * time.Millisecond)
+	}
+	checkCalls(t, &calls, 0)
+
+	clock.Advance(100 * time.Millisecond)
+	checkCalls(t, &calls, 1)
+	if n := d.Pending(); n != 0 {
+		t.Fatalf("处理后仍有 %d 个等待中的键", n)
+	}
+}
+
+func TestMaxWaitDuringContinuousWrites(t *testing.T) {
+	clock := newFakeClock()
+	var calls counter
+	d := New(clock, 100*time.Millisecond, 300*time.Millisecond, func(string) { calls.inc() })
+	defer d.Stop()
+
+	// 每 50ms 写入一次，始终达不到 100ms 的静默时间，第 300ms 时由 maxWait 强制处理一次
+	for i := 0; i < 10; i++ {
+		d.Trigger("state.vscdb")
+		clock.Advance(50 * time.Millisecond)
+	}
+	checkCalls(t, &calls, 1)
+
+	// 写入停止后处理剩余的事件
+	clock.Advance(100 * time.Millisecond)
+	checkCalls(t, &calls, 2)
+}
+
+func TestTriggerDuringProcessingRunsOnceMore(t *testing.T) {
+	clock := newFakeClock()
+	var calls counter
+	var d *Debouncer
+	d = New(clock, 100*time.Millisecond, time.Second, func(key string) {
+		calls.inc()
+		// 第一次处理期间又写入了两次
+		if calls.get() == 1 {
+			d.Trigger(key)
+			d.Trigger(key)
+		}
+	})
+	defer d.Stop()
+
+	d.Trigger("state.vscdb")
+	clock.Advance(100 * time.Millisecond)
+	checkCalls(t, &calls, 1)
+	if n := d.Pending(); n != 1 {
+		t.Fatalf("处理期间的写入应等待再次处理，等待中的键为 %d 个", n)
+	}
+
+	clock.Advance(100 * time.Millisecond)
+	checkCalls(t, &calls, 2)
+
+	clock.Advance(time.Second)
+	checkCalls(t, &calls, 2)
+}
+
+func TestNoCallsAfterStop(t *testing.T) {
+	clock := newFakeClock()
+	var calls counter
+	d := New(clock, 100*time.Millisecond, time.Second, func(string) { calls.inc() })
+
+	d.Trigger("a/state.vscdb")
+	d.Trigger("b/state.vscdb")
+	d.Stop()
+	if n := d.Pending(); n != 0 {
+		t.Fatalf("停止后仍有 %d 个等待中的键", n)
+	}
+
+	d.Trigger("a/state.vscdb")
+	clock.Advance(time.Second)
+	checkCalls(t, &calls, 0)
+}
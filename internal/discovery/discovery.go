@@ -0,0 +1,112 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// OverrideEnv 显式指定 workspaceStorage 目录的环境变量，多个目录用系统路径分隔符分隔
+const OverrideEnv = "CURSOR_HISTORY_STORAGE"
+
+// CursorAppDir Cursor 在用户配置目录下使用的目录名
+const CursorAppDir = "Cursor"
+
+// Candidates 返回编辑器 workspaceStorage 目录在当前平台上的所有候选路径（不检查是否存在）
+func Candidates(appDir string) []string {
+	var candidates []string
+	home, _ := os.UserHomeDir()
+
+	switch runtime.GOOS {
+	case "windows":
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			candidates = append(candidates, storagePath(appData, appDir))
+		}
+	case "darwin":
+		if home != "" {
+			candidates = append(candidates, storagePath(filepath.Join(home, "Library", "Application Support"), appDir))
+		}
+	default:
+		if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+			candidates = append(candidates, storagePath(xdg, appDir))
+		}
+		if home != "" {
+			candidates = append(candidates, storagePath(filepath.Join(home, ".config"), appDir))
+		}
+	}
+
+	// 兜底使用 Go 标准库的用户配置目录
+	if configDir, err := os.UserConfigDir(); err == nil {
+		candidates = append(candidates, storagePath(configDir, appDir))
+	}
+
+	if appDir == CursorAppDir {
+		candidates = append(candidates, portableCandidates()...)
+	}
+	return dedupe(candidates)
+}
+
+// portableCandidates 返回 Cursor 便携版安装的候选路径，便携版数据位于安装目录的 data/user-data 下
+func portableCandidates() []string {
+	var candidates []string
+
+	// VSCODE_PORTABLE 指向便携版的 data 目录
+	if portable := os.Getenv("VSCODE_PORTABLE"); portable != "" {
+		candidates = append(candidates, filepath.Join(portable, "user-data", "User", "workspaceStorage"))
+	}
+
+	// Windows 下 Cursor 默认安装到 %LOCALAPPDATA%\Programs\cursor
+	if localAppData := os.Getenv("LOCALAPPDATA"); runtime.GOOS == "windows" && localAppData != "" {
+		candidates = append(candidates, filepath.Join(localAppData, "Programs", "cursor", "data", "user-data", "User", "workspaceStorage"))
+	}
+
+	return candidates
+}
+
+// Override 返回显式指定的目录，explicit 为空时使用 CURSOR_HISTORY_STORAGE 环境变量
+func Override(explicit string) string {
+	if explicit != "" {
+		return explicit
+	}
+	return os.Getenv(OverrideEnv)
+}
+
+// Resolve 解析编辑器实际存在的 workspaceStorage 目录
+// override 不为空时只使用显式指定的目录（多个目录用系统路径分隔符分隔），否则使用平台默认候选路径
+func Resolve(appDir, override string) []string {
+	candidates := Candidates(appDir)
+	if override != "" {
+		candidates = filepath.SplitList(override)
+	}
+
+	var found []string
+	for _, path := range dedupe(candidates) {
+		if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+			found = append(found, path)
+		}
+	}
+	return found
+}
+
+// storagePath 拼接编辑器的 workspaceStorage 路径
+func storagePath(configDir, appDir string) string {
+	return filepath.Join(configDir, appDir, "User", "workspaceStorage")
+}
+
+// dedupe 去除重复路径，保持原有顺序
+func dedupe(paths []string) []string {
+	seen := make(map[string]bool, len(paths))
+	result := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		clean := filepath.Clean(path)
+		if seen[clean] {
+			continue
+		}
+		seen[clean] = true
+		result = append(result, clean)
+	}
+	return result
+}
@@ -0,0 +1,264 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"cursor_history/internal/storage"
+)
+
+// 导出格式
+const (
+	FormatMarkdown = "markdown"
+	FormatJSON     = "json"
+	FormatJSONL    = "jsonl"
+	FormatCSV      = "csv"
+	FormatHTML     = "html"
+)
+
+// DefaultTitle Markdown 和 HTML 的默认标题
+const DefaultTitle = "提示词记录"
+
+// unknownWorkspace 没有工作区路径的提示词显示的名称
+const unknownWorkspace = "（未知工作区）"
+
+// Formats 返回支持的导出格式
+func Formats() []string {
+	return []string{FormatMarkdown, FormatJSON, FormatJSONL, FormatCSV, FormatHTML}
+}
+
+// ValidateFormat 校验导出格式
+func ValidateFormat(format string) error {
+	for _, f := range Formats() {
+		if format == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("无效的导出格式: %s，可用: %s", format, strings.Join(Formats(), "、"))
+}
+
+// FormatForPath 根据文件扩展名推断导出格式，无法推断时返回 false
+func FormatForPath(path string) (string, bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown":
+		return FormatMarkdown, true
+	case ".json":
+		return FormatJSON, true
+	case ".jsonl", ".ndjson":
+		return FormatJSONL, true
+	case ".csv":
+		return FormatCSV, true
+	case ".html", ".htm":
+		return FormatHTML, true
+	}
+	return "", false
+}
+
+// Options 导出参数
+type Options struct {
+	Title       string    // Markdown 和 HTML 的标题，为空时使用 DefaultTitle
+	GeneratedAt time.Time // 导出时间，零值时使用当前时间
+}
+
+// Day 同一天的提示词
+type Day struct {
+	Date    string                   `json:"date"`
+	Prompts []storage.ArchivedPrompt `json:"prompts"`
+}
+
+// Group 同一工作区的提示词，按日期分组
+type Group struct {
+	Workspace string `json:"workspace"`
+	Count     int    `json:"count"`
+	Days      []Day  `json:"days"`
+}
+
+// Name 返回工作区的显示名称
+func (g Group) Name() string {
+	if g.Workspace == "" {
+		return unknownWorkspace
+	}
+	return g.Workspace
+}
+
+// GroupPrompts 按工作区和日期（本地时间）分组，工作区按路径排序，日期和同一天的提示词按时间先后排序
+// 时间相同的提示词保持原有顺序，同一数据库中的提示词时间相同，因此按数据库中的顺序排列
+func GroupPrompts(prompts []storage.ArchivedPrompt) []Group {
+	sorted := append([]storage.ArchivedPrompt(nil), prompts...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Workspace != b.Workspace {
+			return a.Workspace < b.Workspace
+		}
+		if a.Timestamp != b.Timestamp {
+			return a.Timestamp < b.Timestamp
+		}
+		return a.ID < b.ID
+	})
+
+	var groups []Group
+	for _, p := range sorted {
+		if len(groups) == 0 || groups[len(groups)-1].Workspace != p.Workspace {
+			groups = append(groups, Group{Workspace: p.Workspace})
+		}
+		group := &groups[len(groups)-1]
+		group.Count++
+
+		date := localTime(p.Timestamp).Format("2006-01-02")
+		if len(group.Days) == 0 || group.Days[len(group.Days)-1].Date != date {
+			group.Days = append(group.Days, Day{Date: date})
+		}
+		day := &group.Days[len(group.Days)-1]
+		day.Prompts = append(day.Prompts, p)
+	}
+	return groups
+}
+
+// Write 以指定格式输出提示词，Markdown、JSON 和 HTML 按工作区和日期分组，JSONL 和 CSV 每行一条提示词
+func Write(w io.Writer, format string, prompts []storage.ArchivedPrompt, opts Options) error {
+	if opts.Title == "" {
+		opts.Title = DefaultTitle
+	}
+	if opts.GeneratedAt.IsZero() {
+		opts.GeneratedAt = time.Now()
+	}
+	groups := GroupPrompts(prompts)
+
+	var err error
+	switch format {
+	case FormatMarkdown:
+		err = writeMarkdown(w, groups, len(prompts), opts)
+	case FormatJSON:
+		err = writeJSON(w, groups, len(prompts), opts)
+	case FormatJSONL:
+		err = writeJSONL(w, groups)
+	case FormatCSV:
+		err = writeCSV(w, groups)
+	case FormatHTML:
+		err = writeHTML(w, groups, len(prompts), opts)
+	default:
+		return ValidateFormat(format)
+	}
+	if err != nil {
+		return fmt.Errorf("导出提示词失败: %v", err)
+	}
+	return nil
+}
+
+// localTime 把秒级时间戳转换为本地时间
+func localTime(timestamp int64) time.Time {
+	return time.Unix(timestamp, 0).Local()
+}
+
+// summary 返回导出时间和数量的说明
+func summary(total int, groups []Group, opts Options) string {
+	return fmt.Sprintf("导出时间: %s，共 %d 条提示词，%d 个工作区", opts.GeneratedAt.Format("2006-01-02 15:04:05"), total, len(groups))
+}
+
+// promptMeta 返回提示词的时间、编辑器、命令类型和 Git 分支说明
+func promptMeta(p storage.ArchivedPrompt) string {
+	parts := []string{localTime(p.Timestamp).Format("15:04"), p.Editor, "类型 " + strconv.Itoa(p.CommandType)}
+	if p.GitBranch != "" {
+		parts = append(parts, "分支 "+p.GitBranch)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// writeMarkdown 输出 Markdown，每条提示词放在代码块中，保留原有的换行和格式
+func writeMarkdown(w io.Writer, groups []Group, total int, opts Options) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n%s\n", opts.Title, summary(total, groups, opts))
+	for _, group := range groups {
+		fmt.Fprintf(&b, "\n## %s（%d 条）\n", group.Name(), group.Count)
+		for _, day := range group.Days {
+			fmt.Fprintf(&b, "\n### %s\n", day.Date)
+			for _, p := range day.Prompts {
+				fence := markdownFence(p.Text)
+				fmt.Fprintf(&b, "\n**%s**\n\n%s\n%s\n%s\n", promptMeta(p), fence, p.Text, fence)
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownFence 返回比文本中最长的连续反引号更长的代码块围栏，至少 3 个反引号
+func markdownFence(text string) string {
+	longest, run := 0, 0
+	for _, r := range text {
+		if r != '`' {
+			run = 0
+			continue
+		}
+		if run++; run > longest {
+			longest = run
+		}
+	}
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+// writeJSON 输出按工作区和日期分组的 JSON
+func writeJSON(w io.Writer, groups []Group, total int, opts Options) error {
+	if groups == nil {
+		groups = []Group{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(map[string]interface{}{
+		"title":       opts.Title,
+		"generatedAt": opts.GeneratedAt.Format(time.RFC3339),
+		"total":       total,
+		"workspaces":  groups,
+	})
+}
+
+// writeJSONL 每行输出一条提示词的 JSON
+func writeJSONL(w io.Writer, groups []Group) error {
+	encoder := json.NewEncoder(w)
+	for _, group := range groups {
+		for _, day := range group.Days {
+			for _, p := range day.Prompts {
+				if err := encoder.Encode(p); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// csvHeader CSV 的列名
+var csvHeader = []string{"workspace", "date", "time", "editor", "commandType", "text", "gitRemote", "gitBranch", "gitCommit", "sourceFile"}
+
+// writeCSV 输出 CSV，第一行为列名
+func writeCSV(w io.Writer, groups []Group) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, group := range groups {
+		for _, day := range group.Days {
+			for _, p := range day.Prompts {
+				err := writer.Write([]string{
+					p.Workspace, day.Date, localTime(p.Timestamp).Format("15:04:05"), p.Editor,
+					strconv.Itoa(p.CommandType), p.Text, p.GitRemote, p.GitBranch, p.GitCommit, p.SourceFile,
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,76 @@
+package export
+
+import (
+	"html/template"
+	"io"
+)
+
+// htmlTemplate 不依赖外部资源的单页 HTML，样式内联，可直接作为附件分享
+var htmlTemplate = template.Must(template.New("export").Funcs(template.FuncMap{
+	"meta": promptMeta,
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { margin: 0 auto; max-width: 960px; padding: 24px; font-family: -apple-system, "Segoe UI", "Microsoft YaHei", sans-serif; color: #1f2328; background: #fff; }
+h1 { margin-bottom: 4px; }
+.summary { color: #656d76; margin-top: 0; }
+nav ul { padding-left: 20px; }
+section { margin-top: 32px; }
+h2 { border-bottom: 1px solid #d0d7de; padding-bottom: 6px; word-break: break-all; }
+h3 { color: #656d76; margin-bottom: 8px; }
+.prompt { border: 1px solid #d0d7de; border-radius: 6px; margin: 8px 0; }
+.meta { background: #f6f8fa; border-bottom: 1px solid #d0d7de; color: #656d76; font-size: 13px; padding: 6px 12px; }
+pre { margin: 0; padding: 12px; white-space: pre-wrap; word-break: break-word; font-family: ui-monospace, Consolas, monospace; font-size: 13px; }
+@media (prefers-color-scheme: dark) {
+  body { background: #0d1117; color: #e6edf3; }
+  .prompt, h2, .meta { border-color: #30363d; }
+  .meta { background: #161b22; }
+}
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="summary">{{.Summary}}</p>
+{{- if .Groups}}
+<nav>
+<ul>
+{{- range $i, $g := .Groups}}
+<li><a href="#ws-{{$i}}">{{$g.Name}}</a>（{{$g.Count}} 条）</li>
+{{- end}}
+</ul>
+</nav>
+{{- end}}
+{{- range $i, $g := .Groups}}
+<section id="ws-{{$i}}">
+<h2>{{$g.Name}}</h2>
+{{- range $g.Days}}
+<h3>{{.Date}}</h3>
+{{- range .Prompts}}
+<div class="prompt">
+<div class="meta">{{meta .}}</div>
+<pre>{{.Text}}</pre>
+</div>
+{{- end}}
+{{- end}}
+</section>
+{{- end}}
+</body>
+</html>
+`))
+
+// writeHTML 输出按工作区和日期分组的单页 HTML
+func writeHTML(w io.Writer, groups []Group, total int, opts Options) error {
+	return htmlTemplate.Execute(w, struct {
+		Title   string
+		Summary string
+		Groups  []Group
+	}{
+		Title:   opts.Title,
+		Summary: summary(total, groups, opts),
+		Groups:  groups,
+	})
+}
@@ -1,15 +1,19 @@
+//go:build windows
+
 package gui
 
 import (
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
 	"cursor_history/internal/app"
+	"cursor_history/internal/logging"
+	"cursor_history/internal/source"
 	"cursor_history/internal/storage"
+	"cursor_history/internal/types"
 	"cursor_history/internal/upload"
 
 	"github.com/lxn/walk"
@@ -25,6 +29,7 @@ const (
 	LogLevelSuccess = "SUCCESS"
 	LogLevelInfo    = "INFO"
 	LogLevelDefault = "DEFAULT"
+	LogLevelDebug   = "DEBUG"
 )
 
 // GUI 结构体
@@ -49,6 +54,7 @@ type GUI struct {
 	statusIndicator   *walk.StatusBarItem
 	runningIcon       *walk.Icon
 	stoppedIcon       *walk.Icon
+	logOutput         types.Logger // 同时写入的日志记录器，可为空
 }
 
 // 优化内存分配
@@ -223,12 +229,7 @@ func NewGUI(configManager *storage.ConfigManager) (*GUI, error) {
 			gui.startMonitoring(configManager)
 		} else {
 			gui.Log(LogLevelInfo, "停止监控")
-			upload.CloseWatcher()
-			close(gui.stopChan)
-			gui.isMonitoring = false
-			gui.isAutoStarting = false
-			gui.countdownStopped = true // 确保倒计时停止
-			gui.updateStatus()
+			gui.stopMonitoring()
 		}
 	})
 
@@ -251,19 +252,33 @@ func (gui *GUI) NewTray() (*Tray, error) {
 	return NewTray(gui.window)
 }
 
+// SetLogOutput 设置同时写入的日志记录器，如日志文件和控制接口的日志缓冲，日志窗口中的每条日志都会写入
+func (gui *GUI) SetLogOutput(logger types.Logger) {
+	gui.logOutput = logger
+}
+
 // Log 记录日志
 func (gui *GUI) Log(level string, format string, args ...interface{}) {
+	gui.LogEntry(logging.Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// LogEntry 记录一条带组件和字段的日志，组件和字段以文本形式显示，日志文件支持时按结构化格式写入
+func (gui *GUI) LogEntry(entry logging.Entry) {
 	// 从对象池获取 Builder
 	builder := messagePool.Get().(*strings.Builder)
 	builder.Reset()
 	defer messagePool.Put(builder)
 
 	// 格式化时间
-	builder.WriteString(time.Now().Format("2006-01-02 15:04:05"))
+	builder.WriteString(entry.Time.Format("2006-01-02 15:04:05"))
 	builder.WriteByte(' ')
 
 	// 添加日志级别
-	switch level {
+	switch entry.Level {
 	case LogLevelError:
 		builder.WriteString("[错误]")
 	case LogLevelWarning:
@@ -272,23 +287,32 @@ func (gui *GUI) Log(level string, format string, args ...interface{}) {
 		builder.WriteString("[成功]")
 	case LogLevelInfo:
 		builder.WriteString("[信息]")
+	case LogLevelDebug:
+		builder.WriteString("[调试]")
 	default:
 		builder.WriteString("[日志]")
 	}
 	builder.WriteByte(' ')
 
 	// 格式化消息
-	fmt.Fprintf(builder, format, args...)
+	builder.WriteString(logging.EntryText(entry))
 	builder.WriteString("\r\n")
 
+	if l, ok := gui.logOutput.(logging.EntryLogger); ok {
+		l.LogEntry(entry)
+	} else if gui.logOutput != nil {
+		gui.logOutput.Log(entry.Level, "%s", logging.EntryText(entry))
+	}
+
 	// 在主线程更新 UI
+	text := builder.String()
 	gui.window.Synchronize(func() {
 		// 获取当前文本长度
 		currentLen := gui.logView.TextLength()
 
 		// 追加新文本
 		gui.logView.SetTextSelection(currentLen, currentLen)
-		gui.logView.ReplaceSelectedText(builder.String(), false)
+		gui.logView.ReplaceSelectedText(text, false)
 
 		// 滚动到底部
 		gui.logView.SendMessage(win.WM_VSCROLL, win.SB_BOTTOM, 0)
@@ -374,11 +398,18 @@ func (gui *GUI) startMonitoring(configManager *storage.ConfigManager) {
 	// 在新的 goroutine 中启动监控
 	go func() {
 		gui.Log(LogLevelInfo, "开始监控目录")
-		searchPath := filepath.Join(os.Getenv("APPDATA"), "Cursor", "User", "workspaceStorage")
+		override, err := configManager.LoadStorageDirs()
+		if err != nil {
+			gui.Log(LogLevelError, "%v", err)
+		}
+		roots := source.Roots(override)
+		if len(roots) == 0 {
+			gui.Log(LogLevelWarning, "未找到 workspaceStorage 目录，已检查: %s", strings.Join(source.CandidatePaths(), ", "))
+		}
 
 		done := make(chan error, 1)
 		go func() {
-			done <- upload.WatchDirectory(searchPath, configManager, gui)
+			done <- upload.WatchDirectory(roots, configManager, gui)
 		}()
 
 		select {
@@ -400,6 +431,50 @@ func (gui *GUI) startMonitoring(configManager *storage.ConfigManager) {
 	}()
 }
 
+// stopMonitoring 停止监控并更新界面状态，必须在界面线程中调用
+func (gui *GUI) stopMonitoring() {
+	upload.CloseWatcher()
+	close(gui.stopChan)
+	gui.isMonitoring = false
+	gui.isAutoStarting = false
+	gui.countdownStopped = true // 确保倒计时停止
+	gui.updateStatus()
+}
+
+// StartMonitoring 供控制接口开始监控，在界面线程中执行，与点击开始按钮相同
+func (gui *GUI) StartMonitoring() error {
+	result := make(chan error, 1)
+	gui.window.Synchronize(func() {
+		if gui.isMonitoring {
+			result <- fmt.Errorf("监控已在运行")
+			return
+		}
+		gui.countdownStopped = true // 停止倒计时
+		gui.countdownSec = 0
+		gui.startMonitoring(gui.config)
+		if !gui.isMonitoring {
+			result <- fmt.Errorf("开始监控失败，请查看日志")
+			return
+		}
+		result <- nil
+	})
+	return <-result
+}
+
+// StopMonitoring 供控制接口停止监控，在界面线程中执行，与点击停止按钮相同
+func (gui *GUI) StopMonitoring() error {
+	result := make(chan error, 1)
+	gui.window.Synchronize(func() {
+		if !gui.isMonitoring {
+			result <- upload.ErrNotMonitoring
+			return
+		}
+		gui.stopMonitoring()
+		result <- nil
+	})
+	return <-result
+}
+
 // 添加更新状态的方法
 func (gui *GUI) updateStatus() {
 	gui.window.Synchronize(func() {
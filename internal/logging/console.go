@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"cursor_history/internal/types"
+)
+
+// ConsoleLogger 将日志输出到标准输出，并可同时追加写入日志文件，实现 types.Logger 接口
+type ConsoleLogger struct {
+	mu   sync.Mutex
+	out  io.Writer
+	file *os.File
+}
+
+// NewConsoleLogger 创建控制台日志记录器，logPath 为空时只输出到标准输出
+func NewConsoleLogger(logPath string) (*ConsoleLogger, error) {
+	l := &ConsoleLogger{out: os.Stdout}
+	if logPath == "" {
+		return l, nil
+	}
+
+	file, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开日志文件失败: %v", err)
+	}
+	l.file = file
+	l.out = io.MultiWriter(os.Stdout, file)
+	return l, nil
+}
+
+// NewStderrLogger 创建输出到标准错误的控制台日志记录器，用于标准输出另作他用的命令
+func NewStderrLogger() *ConsoleLogger {
+	return &ConsoleLogger{out: os.Stderr}
+}
+
+// Log 记录日志
+func (l *ConsoleLogger) Log(level string, format string, args ...interface{}) {
+	l.LogEntry(Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// LogEntry 记录一条带组件和字段的日志，组件和字段以文本形式附加在消息前后
+func (l *ConsoleLogger) LogEntry(entry Entry) {
+	var builder strings.Builder
+	builder.WriteString(entry.Time.Format("2006-01-02 15:04:05"))
+	builder.WriteByte(' ')
+	builder.WriteString(levelLabel(entry.Level))
+	builder.WriteByte(' ')
+	builder.WriteString(EntryText(entry))
+	builder.WriteByte('\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	io.WriteString(l.out, builder.String())
+}
+
+// Close 关闭日志文件
+func (l *ConsoleLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file != nil {
+		err := l.file.Close()
+		l.file = nil
+		l.out = os.Stdout
+		return err
+	}
+	return nil
+}
+
+// levelLabel 返回日志级别对应的显示标签，与 GUI 日志窗口保持一致
+func levelLabel(level string) string {
+	switch level {
+	case types.LogLevelError:
+		return "[错误]"
+	case types.LogLevelWarning:
+		return "[警告]"
+	case types.LogLevelSuccess:
+		return "[成功]"
+	case types.LogLevelInfo:
+		return "[信息]"
+	case types.LogLevelDebug:
+		return "[调试]"
+	default:
+		return "[日志]"
+	}
+}
@@ -0,0 +1,432 @@
+package logging
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cursor_history/internal/types"
+)
+
+// 日志文件格式
+const (
+	FormatText = "text" // 与控制台一致的文本行
+	FormatJSON = "json" // 每行一个 JSON 对象
+)
+
+// ValidateFormat 校验日志文件格式
+func ValidateFormat(format string) error {
+	switch format {
+	case FormatText, FormatJSON:
+		return nil
+	}
+	return fmt.Errorf("无效的日志格式: %s，可用: %s、%s", format, FormatText, FormatJSON)
+}
+
+// FileOptions 日志文件参数
+type FileOptions struct {
+	Path       string
+	Format     string
+	MaxSize    int64         // 单个文件的最大字节数，超过后轮转，0 表示不限制
+	MaxAge     time.Duration // 从本地零点起按该时长划分时间段，写入跨越时间段时轮转，0 表示不按时间轮转
+	MaxBackups int           // 保留的历史文件数，0 表示全部保留
+	Compress   bool          // 是否用 gzip 压缩历史文件
+}
+
+// DefaultFileOptions 默认日志文件参数
+func DefaultFileOptions(path string) FileOptions {
+	return FileOptions{
+		Path:       path,
+		Format:     FormatText,
+		MaxSize:    10 << 20,
+		MaxAge:     24 * time.Hour,
+		MaxBackups: 7,
+	}
+}
+
+// Entry 一条日志
+type Entry struct {
+	Time      time.Time
+	Level     string
+	Component string
+	Message   string
+	Fields    map[string]interface{}
+}
+
+// jsonEntry JSON 格式日志行
+type jsonEntry struct {
+	Time      string                 `json:"time"`
+	Level     string                 `json:"level"`
+	Component string                 `json:"component,omitempty"`
+	Message   string                 `json:"msg"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+}
+
+// FileLogger 写入日志文件并按大小和时间轮转，实现 types.Logger 接口
+// 同时实现 io.Writer，可作为标准库 log 的输出，每行按 INFO 级别记录
+type FileLogger struct {
+	mu        sync.Mutex
+	opts      FileOptions
+	file      *os.File
+	size      int64
+	lastWrite time.Time
+	compress  sync.WaitGroup
+	cleanup   sync.Mutex // 历史文件的压缩和清理逐个进行，避免清理时把正在压缩的文件算作两个
+}
+
+// NewFileLogger 打开日志文件，已存在时继续追加
+func NewFileLogger(opts FileOptions) (*FileLogger, error) {
+	if opts.Format == "" {
+		opts.Format = FormatText
+	}
+	if err := ValidateFormat(opts.Format); err != nil {
+		return nil, err
+	}
+
+	l := &FileLogger{opts: opts}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Configure 修改轮转参数和格式，日志文件路径不变
+func (l *FileLogger) Configure(opts FileOptions) error {
+	if opts.Format == "" {
+		opts.Format = FormatText
+	}
+	if err := ValidateFormat(opts.Format); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	opts.Path = l.opts.Path
+	l.opts = opts
+	return nil
+}
+
+// Log 记录日志
+func (l *FileLogger) Log(level string, format string, args ...interface{}) {
+	l.LogEntry(Entry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// LogEntry 记录一条带组件和字段的日志
+func (l *FileLogger) LogEntry(entry Entry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return
+	}
+
+	line := l.format(entry)
+	if l.shouldRotate(entry.Time, int64(len(line))) {
+		if err := l.rotate(entry.Time); err != nil {
+			fmt.Fprintf(os.Stderr, "轮转日志文件失败: %v\n", err)
+			if l.file == nil {
+				return
+			}
+		}
+	}
+
+	n, _ := io.WriteString(l.file, line)
+	l.size += int64(n)
+	l.lastWrite = entry.Time
+}
+
+// Write 实现 io.Writer，按行记录为 INFO 日志
+func (l *FileLogger) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		l.LogEntry(Entry{Level: types.LogLevelInfo, Message: line})
+	}
+	return len(p), nil
+}
+
+// Close 关闭日志文件，并等待正在进行的压缩完成
+func (l *FileLogger) Close() error {
+	l.mu.Lock()
+	var err error
+	if l.file != nil {
+		err = l.file.Close()
+		l.file = nil
+	}
+	l.mu.Unlock()
+
+	l.compress.Wait()
+	return err
+}
+
+// format 按配置的格式生成日志行
+func (l *FileLogger) format(entry Entry) string {
+	if l.opts.Format == FormatJSON {
+		data, err := json.Marshal(jsonEntry{
+			Time:      entry.Time.Format(time.RFC3339Nano),
+			Level:     entry.Level,
+			Component: entry.Component,
+			Message:   entry.Message,
+			Fields:    entry.Fields,
+		})
+		if err != nil {
+			data, _ = json.Marshal(jsonEntry{
+				Time:    entry.Time.Format(time.RFC3339Nano),
+				Level:   entry.Level,
+				Message: fmt.Sprintf("%s (字段序列化失败: %v)", entry.Message, err),
+			})
+		}
+		return string(data) + "\n"
+	}
+
+	var builder strings.Builder
+	builder.WriteString(entry.Time.Format("2006-01-02 15:04:05"))
+	builder.WriteByte(' ')
+	builder.WriteString(levelLabel(entry.Level))
+	builder.WriteByte(' ')
+	builder.WriteString(EntryText(entry))
+	builder.WriteByte('\n')
+	return builder.String()
+}
+
+// open 打开日志文件，已有内容时按其修改时间判断所属时间段
+func (l *FileLogger) open() error {
+	if dir := filepath.Dir(l.opts.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("创建日志目录失败: %v", err)
+		}
+	}
+
+	file, err := os.OpenFile(l.opts.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("打开日志文件失败: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("读取日志文件信息失败: %v", err)
+	}
+
+	l.file = file
+	l.size = info.Size()
+	l.lastWrite = time.Time{}
+	if l.size > 0 {
+		l.lastWrite = info.ModTime()
+	}
+	return nil
+}
+
+// shouldRotate 判断写入 n 字节前是否需要轮转
+func (l *FileLogger) shouldRotate(now time.Time, n int64) bool {
+	if l.size == 0 {
+		return false
+	}
+	if l.opts.MaxSize > 0 && l.size+n > l.opts.MaxSize {
+		return true
+	}
+	if l.opts.MaxAge > 0 && !l.lastWrite.IsZero() {
+		return !periodStart(now, l.opts.MaxAge).Equal(periodStart(l.lastWrite, l.opts.MaxAge))
+	}
+	return false
+}
+
+// periodStart 返回 t 所在时间段的开始时间，时间段从 t 所在时区的零点开始划分
+// 不能用 Truncate，它按 UTC 划分，东八区会在早上 8 点轮转
+func periodStart(t time.Time, d time.Duration) time.Time {
+	year, month, day := t.Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	if d < 24*time.Hour {
+		return midnight.Add(t.Sub(midnight) / d * d)
+	}
+
+	// 一天以上按整天划分，从 1970-01-01 起每 days 天为一段
+	days := int64(d / (24 * time.Hour))
+	n := time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix() / 86400
+	return midnight.AddDate(0, 0, -int(n%days))
+}
+
+// rotate 将当前文件改名为历史文件并重新打开，然后按需压缩和清理历史文件
+// 历史文件以最后一次写入的时间命名，即其中最后一条日志的时间
+func (l *FileLogger) rotate(now time.Time) error {
+	if err := l.file.Close(); err != nil {
+		return fmt.Errorf("关闭日志文件失败: %v", err)
+	}
+	l.file = nil
+
+	stamp := l.lastWrite
+	if stamp.IsZero() {
+		stamp = now
+	}
+	backup := l.backupName(stamp)
+	renameErr := os.Rename(l.opts.Path, backup)
+	if err := l.open(); err != nil {
+		return err
+	}
+	if renameErr != nil {
+		return fmt.Errorf("重命名日志文件失败: %v", renameErr)
+	}
+
+	opts := l.opts
+	l.compress.Add(1)
+	go func() {
+		defer l.compress.Done()
+		l.cleanup.Lock()
+		defer l.cleanup.Unlock()
+		if opts.Compress {
+			if err := compressFile(backup); err != nil {
+				fmt.Fprintf(os.Stderr, "压缩日志文件失败: %v\n", err)
+			}
+		}
+		if err := pruneBackups(opts.Path, opts.MaxBackups); err != nil {
+			fmt.Fprintf(os.Stderr, "清理历史日志失败: %v\n", err)
+		}
+	}()
+	return nil
+}
+
+// backupName 生成历史文件名，如 cursor-20240131-150405.log，同一秒内多次轮转时追加序号
+func (l *FileLogger) backupName(t time.Time) string {
+	prefix, ext := splitLogPath(l.opts.Path)
+	stamp := t.Format("20060102-150405")
+
+	name := fmt.Sprintf("%s-%s%s", prefix, stamp, ext)
+	for i := 1; ; i++ {
+		_, err := os.Stat(name)
+		_, gzErr := os.Stat(name + ".gz")
+		if os.IsNotExist(err) && os.IsNotExist(gzErr) {
+			return name
+		}
+		name = fmt.Sprintf("%s-%s.%d%s", prefix, stamp, i, ext)
+	}
+}
+
+// splitLogPath 拆分日志路径的扩展名，历史文件名插入在两者之间
+func splitLogPath(path string) (prefix, ext string) {
+	ext = filepath.Ext(path)
+	return strings.TrimSuffix(path, ext), ext
+}
+
+// compressFile 将文件压缩为 .gz 后删除原文件
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(dst)
+	if _, err := io.Copy(writer, src); err != nil {
+		writer.Close()
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	src.Close()
+	return os.Remove(path)
+}
+
+// pruneBackups 只保留最新的 maxBackups 个历史文件
+func pruneBackups(path string, maxBackups int) error {
+	if maxBackups <= 0 {
+		return nil
+	}
+
+	backups, err := listBackups(path)
+	if err != nil {
+		return err
+	}
+	if len(backups) <= maxBackups {
+		return nil
+	}
+	for _, backup := range backups[:len(backups)-maxBackups] {
+		if err := os.Remove(backup); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// listBackups 列出日志文件的历史文件，按轮转时间从旧到新排序
+func listBackups(path string) ([]string, error) {
+	prefix, ext := splitLogPath(path)
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	type backup struct {
+		path  string
+		stamp string
+		seq   int
+	}
+	base := filepath.Base(prefix) + "-"
+	var backups []backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, base) {
+			continue
+		}
+		// 去掉前缀、扩展名和 .gz 后剩下 时间戳[.序号]
+		rest := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, base), ".gz"), ext)
+		stamp, seq, _ := strings.Cut(rest, ".")
+		if _, err := time.Parse("20060102-150405", stamp); err != nil {
+			continue
+		}
+		b := backup{path: filepath.Join(filepath.Dir(path), name), stamp: stamp}
+		if seq != "" {
+			if b.seq, err = strconv.Atoi(seq); err != nil {
+				continue
+			}
+		}
+		backups = append(backups, b)
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].stamp != backups[j].stamp {
+			return backups[i].stamp < backups[j].stamp
+		}
+		return backups[i].seq < backups[j].seq
+	})
+	paths := make([]string, len(backups))
+	for i, b := range backups {
+		paths[i] = b.path
+	}
+	return paths, nil
+}
+
+// sortedKeys 返回按字母排序的字段名，保证文本格式输出稳定
+func sortedKeys(fields map[string]interface{}) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
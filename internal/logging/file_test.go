@@ -0,0 +1,169 @@
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cursor_history/internal/types"
+)
+
+// newTestFileLogger 在临时目录中创建日志文件，返回记录器和日志文件路径
+func newTestFileLogger(t *testing.T, opts FileOptions) (*FileLogger, string) {
+	t.Helper()
+	opts.Path = filepath.Join(t.TempDir(), "cursor.log")
+	l, err := NewFileLogger(opts)
+	if err != nil {
+		t.Fatalf("创建日志文件失败: %v", err)
+	}
+	return l, opts.Path
+}
+
+// 超过 MaxSize 时轮转，历史文件以其中最后一条日志的时间命名
+func TestFileLoggerRotateBySize(t *testing.T) {
+	l, path := newTestFileLogger(t, FileOptions{MaxSize: 100})
+
+	first := time.Date(2024, 1, 31, 15, 4, 5, 0, time.Local)
+	l.LogEntry(Entry{Time: first, Level: types.LogLevelInfo, Message: "第一条"})
+	l.LogEntry(Entry{Time: first.Add(time.Second), Level: types.LogLevelInfo, Message: "第二条"})
+	l.LogEntry(Entry{Time: first.Add(time.Hour), Level: types.LogLevelInfo, Message: "第三条，超过大小限制"})
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := listBackups(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("历史文件为 %v，期望 1 个", backups)
+	}
+	if want := filepath.Join(filepath.Dir(path), "cursor-20240131-150406.log"); backups[0] != want {
+		t.Fatalf("历史文件为 %s，期望以最后一次写入的时间命名为 %s", backups[0], want)
+	}
+
+	data, err := os.ReadFile(backups[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "第一条") || !strings.Contains(string(data), "第二条") {
+		t.Fatalf("历史文件内容为 %q", data)
+	}
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "第三条") || strings.Contains(string(data), "第一条") {
+		t.Fatalf("日志文件内容为 %q", data)
+	}
+}
+
+// 超过 MaxBackups 时只保留最新的历史文件，同一秒内多次轮转按序号排序
+func TestFileLoggerPruneBackups(t *testing.T) {
+	l, path := newTestFileLogger(t, FileOptions{MaxSize: 1, MaxBackups: 2})
+
+	base := time.Date(2024, 1, 31, 15, 0, 0, 0, time.Local)
+	times := []time.Time{base, base.Add(time.Minute), base.Add(2 * time.Minute), base.Add(2 * time.Minute), base.Add(3 * time.Minute)}
+	for i, at := range times {
+		l.LogEntry(Entry{Time: at, Level: types.LogLevelInfo, Message: string(rune('a' + i))})
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := listBackups(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Dir(path)
+	want := []string{
+		filepath.Join(dir, "cursor-20240131-150200.log"),
+		filepath.Join(dir, "cursor-20240131-150200.1.log"),
+	}
+	if strings.Join(backups, ",") != strings.Join(want, ",") {
+		t.Fatalf("保留的历史文件为 %v，期望 %v", backups, want)
+	}
+	data, err := os.ReadFile(want[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), " d") {
+		t.Fatalf("最新的历史文件内容为 %q，期望第 4 条日志", data)
+	}
+}
+
+// 开启压缩时历史文件压缩为 .gz，原文件被删除
+func TestFileLoggerCompressBackups(t *testing.T) {
+	l, path := newTestFileLogger(t, FileOptions{MaxSize: 1, Compress: true})
+
+	at := time.Date(2024, 1, 31, 15, 4, 5, 0, time.Local)
+	l.LogEntry(Entry{Time: at, Level: types.LogLevelInfo, Message: "压缩前的日志"})
+	l.LogEntry(Entry{Time: at.Add(time.Second), Level: types.LogLevelInfo, Message: "新日志"})
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backup := filepath.Join(filepath.Dir(path), "cursor-20240131-150405.log")
+	if _, err := os.Stat(backup); !os.IsNotExist(err) {
+		t.Fatalf("压缩后原历史文件应被删除: %v", err)
+	}
+	file, err := os.Open(backup + ".gz")
+	if err != nil {
+		t.Fatalf("没有生成压缩文件: %v", err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "压缩前的日志") {
+		t.Fatalf("压缩文件内容为 %q", data)
+	}
+
+	backups, err := listBackups(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 || backups[0] != backup+".gz" {
+		t.Fatalf("历史文件为 %v", backups)
+	}
+}
+
+// 按时间轮转从本地零点开始划分时间段，而不是 UTC 零点
+func TestPeriodStartLocalMidnight(t *testing.T) {
+	zone := time.FixedZone("CST", 8*3600)
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2024, 1, day, hour, min, 0, 0, zone)
+	}
+
+	tests := []struct {
+		name string
+		a, b time.Time
+		d    time.Duration
+		same bool
+	}{
+		{"同一天跨过 UTC 零点", at(31, 7, 0), at(31, 9, 0), 24 * time.Hour, true},
+		{"跨过本地零点", at(30, 23, 59), at(31, 0, 1), 24 * time.Hour, false},
+		{"六小时的同一段", at(31, 6, 0), at(31, 11, 59), 6 * time.Hour, true},
+		{"六小时跨段", at(31, 5, 59), at(31, 6, 0), 6 * time.Hour, false},
+		{"两天的同一段", at(2, 0, 0), at(3, 23, 59), 48 * time.Hour, true},
+		{"两天跨段", at(3, 23, 59), at(4, 0, 0), 48 * time.Hour, false},
+	}
+	for _, tt := range tests {
+		same := periodStart(tt.a, tt.d).Equal(periodStart(tt.b, tt.d))
+		if same != tt.same {
+			t.Fatalf("%s: %v 与 %v 是否同一时间段为 %v，期望 %v", tt.name, tt.a, tt.b, same, tt.same)
+		}
+	}
+	if start := periodStart(at(31, 9, 30), 24*time.Hour); !start.Equal(at(31, 0, 0)) {
+		t.Fatalf("时间段开始于 %v，期望本地零点", start)
+	}
+}
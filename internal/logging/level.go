@@ -0,0 +1,145 @@
+package logging
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"cursor_history/internal/types"
+)
+
+// Level 日志级别，值越大越重要
+type Level int
+
+// 日志级别
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelSuccess
+	LevelWarn
+	LevelError
+)
+
+// String 返回级别对应的 types.Logger 级别字符串
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return types.LogLevelDebug
+	case LevelSuccess:
+		return types.LogLevelSuccess
+	case LevelWarn:
+		return types.LogLevelWarning
+	case LevelError:
+		return types.LogLevelError
+	default:
+		return types.LogLevelInfo
+	}
+}
+
+// ParseLevel 解析日志级别名称，不区分大小写，可用: debug、info、success、warn（warning）、error
+func ParseLevel(name string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "success":
+		return LevelSuccess, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("无效的日志级别: %s，可用: debug、info、success、warn、error", name)
+}
+
+// LevelOf 返回 types.Logger 级别字符串对应的级别，未知级别（如 DEFAULT）视为 INFO
+func LevelOf(level string) Level {
+	switch level {
+	case types.LogLevelDebug:
+		return LevelDebug
+	case types.LogLevelSuccess:
+		return LevelSuccess
+	case types.LogLevelWarning:
+		return LevelWarn
+	case types.LogLevelError:
+		return LevelError
+	default:
+		return LevelInfo
+	}
+}
+
+// Levels 各组件的最低日志级别，未单独配置的组件使用 Default
+type Levels struct {
+	Default    Level
+	Components map[string]Level
+}
+
+// DefaultLevels 默认只记录 INFO 及以上的日志
+func DefaultLevels() Levels {
+	return Levels{Default: LevelInfo}
+}
+
+// ParseLevels 解析日志级别配置，如 "info,watcher=debug"
+// 不带组件名的项设置默认级别，组件名=级别 的项设置该组件的级别，为空时使用默认配置
+func ParseLevels(spec string) (Levels, error) {
+	levels := DefaultLevels()
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		component, name, ok := strings.Cut(item, "=")
+		if !ok {
+			level, err := ParseLevel(item)
+			if err != nil {
+				return levels, err
+			}
+			levels.Default = level
+			continue
+		}
+
+		component = strings.TrimSpace(component)
+		if component == "" {
+			return levels, fmt.Errorf("无效的日志级别配置: %s，缺少组件名", item)
+		}
+		level, err := ParseLevel(name)
+		if err != nil {
+			return levels, err
+		}
+		if levels.Components == nil {
+			levels.Components = make(map[string]Level)
+		}
+		levels.Components[component] = level
+	}
+	return levels, nil
+}
+
+// ValidateLevels 校验日志级别配置
+func ValidateLevels(spec string) error {
+	_, err := ParseLevels(spec)
+	return err
+}
+
+// Enabled 判断组件的日志级别是否需要记录
+func (l Levels) Enabled(component string, level Level) bool {
+	if min, ok := l.Components[component]; ok {
+		return level >= min
+	}
+	return level >= l.Default
+}
+
+// String 返回可被 ParseLevels 解析的配置字符串
+func (l Levels) String() string {
+	items := []string{strings.ToLower(l.Default.String())}
+	components := make([]string, 0, len(l.Components))
+	for component := range l.Components {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+	for _, component := range components {
+		items = append(items, component+"="+strings.ToLower(l.Components[component].String()))
+	}
+	return strings.Join(items, ",")
+}
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"testing"
+)
+
+func TestParseLevels(t *testing.T) {
+	tests := []struct {
+		spec       string
+		want       Level
+		components map[string]Level
+		str        string
+	}{
+		{"", LevelInfo, nil, "info"},
+		{"info,watcher=debug", LevelInfo, map[string]Level{"watcher": LevelDebug}, "info,watcher=debug"},
+		{" WARNING , sender = error ,", LevelWarn, map[string]Level{"sender": LevelError}, "warn,sender=error"},
+		{"debug,git=warn,scan=success", LevelDebug, map[string]Level{"git": LevelWarn, "scan": LevelSuccess}, "debug,git=warn,scan=success"},
+	}
+	for _, tt := range tests {
+		levels, err := ParseLevels(tt.spec)
+		if err != nil {
+			t.Fatalf("解析 %q 失败: %v", tt.spec, err)
+		}
+		if levels.Default != tt.want {
+			t.Fatalf("%q 的默认级别为 %v，期望 %v", tt.spec, levels.Default, tt.want)
+		}
+		if len(levels.Components) != len(tt.components) {
+			t.Fatalf("%q 的组件级别为 %v，期望 %v", tt.spec, levels.Components, tt.components)
+		}
+		for component, level := range tt.components {
+			if got, ok := levels.Components[component]; !ok || got != level {
+				t.Fatalf("%q 中 %s 的级别为 %v，期望 %v", tt.spec, component, got, level)
+			}
+		}
+		if s := levels.String(); s != tt.str {
+			t.Fatalf("%q 格式化为 %q，期望 %q", tt.spec, s, tt.str)
+		}
+	}
+}
+
+func TestParseLevelsErrors(t *testing.T) {
+	for _, spec := range []string{"verbose", "info,=debug", "watcher=loud"} {
+		if err := ValidateLevels(spec); err == nil {
+			t.Fatalf("日志级别配置 %q 应当无效", spec)
+		}
+	}
+}
+
+// 单独配置的组件使用自己的级别，其他组件回退到默认级别
+func TestLevelsEnabled(t *testing.T) {
+	levels, err := ParseLevels("warn,watcher=debug,sender=error")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		component string
+		level     Level
+		want      bool
+	}{
+		{"watcher", LevelDebug, true},
+		{"sender", LevelWarn, false},
+		{"sender", LevelError, true},
+		{"git", LevelInfo, false},
+		{"git", LevelWarn, true},
+		{"", LevelSuccess, false},
+		{"", LevelError, true},
+	}
+	for _, tt := range tests {
+		if got := levels.Enabled(tt.component, tt.level); got != tt.want {
+			t.Fatalf("组件 %q 的 %v 日志是否记录为 %v，期望 %v", tt.component, tt.level, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"cursor_history/internal/types"
+)
+
+// EntryLogger 可直接记录结构化日志的记录器，未实现时组件和字段会拼接到消息文本中
+type EntryLogger interface {
+	LogEntry(entry Entry)
+}
+
+// Logger 带级别过滤、组件名和键值字段的日志记录器，输出到一个 types.Logger
+// 同时实现 types.Logger 接口，原有的 Log 调用按级别字符串过滤后照常输出
+type Logger struct {
+	out       types.Logger
+	levels    Levels
+	component string
+	fields    []interface{}
+}
+
+// New 创建输出到 out 的日志记录器，out 已是 *Logger 时保留其组件和字段，只替换级别配置
+func New(out types.Logger, levels Levels) *Logger {
+	if l, ok := out.(*Logger); ok {
+		derived := *l
+		derived.levels = levels
+		return &derived
+	}
+	return &Logger{out: out, levels: levels}
+}
+
+// From 把 types.Logger 转换为 *Logger，已是 *Logger 时直接返回，否则使用默认级别配置
+func From(logger types.Logger) *Logger {
+	if l, ok := logger.(*Logger); ok {
+		return l
+	}
+	return New(logger, DefaultLevels())
+}
+
+// Component 返回属于指定组件的日志记录器，组件可单独配置日志级别
+func (l *Logger) Component(name string) *Logger {
+	derived := *l
+	derived.component = name
+	return &derived
+}
+
+// With 返回附带键值字段的日志记录器，kv 为交替的键和值
+func (l *Logger) With(kv ...interface{}) *Logger {
+	derived := *l
+	derived.fields = append(append([]interface{}(nil), l.fields...), kv...)
+	return &derived
+}
+
+// Enabled 判断当前组件是否记录该级别的日志，可用于跳过代价较高的字段计算
+func (l *Logger) Enabled(level Level) bool {
+	return l.levels.Enabled(l.component, level)
+}
+
+// Debug 记录调试日志，kv 为交替的键和值
+func (l *Logger) Debug(msg string, kv ...interface{}) {
+	l.write(LevelDebug, LevelDebug.String(), msg, kv)
+}
+
+// Info 记录信息日志
+func (l *Logger) Info(msg string, kv ...interface{}) {
+	l.write(LevelInfo, LevelInfo.String(), msg, kv)
+}
+
+// Success 记录成功日志
+func (l *Logger) Success(msg string, kv ...interface{}) {
+	l.write(LevelSuccess, LevelSuccess.String(), msg, kv)
+}
+
+// Warn 记录警告日志
+func (l *Logger) Warn(msg string, kv ...interface{}) {
+	l.write(LevelWarn, LevelWarn.String(), msg, kv)
+}
+
+// Error 记录错误日志
+func (l *Logger) Error(msg string, kv ...interface{}) {
+	l.write(LevelError, LevelError.String(), msg, kv)
+}
+
+// Log 实现 types.Logger 接口，按级别字符串过滤后记录格式化的消息
+func (l *Logger) Log(level string, format string, args ...interface{}) {
+	if !l.Enabled(LevelOf(level)) {
+		return
+	}
+	l.write(LevelOf(level), level, fmt.Sprintf(format, args...), nil)
+}
+
+// Close 关闭输出的日志记录器
+func (l *Logger) Close() error {
+	if l.out == nil {
+		return nil
+	}
+	return l.out.Close()
+}
+
+// write 过滤级别后输出一条日志，label 为原始的级别字符串，保留 DEFAULT 等旧级别的显示
+func (l *Logger) write(level Level, label string, msg string, kv []interface{}) {
+	if l.out == nil || !l.Enabled(level) {
+		return
+	}
+
+	entry := Entry{
+		Time:      time.Now(),
+		Level:     label,
+		Component: l.component,
+		Message:   msg,
+		Fields:    fieldMap(l.fields, kv),
+	}
+	if out, ok := l.out.(EntryLogger); ok {
+		out.LogEntry(entry)
+		return
+	}
+	l.out.Log(entry.Level, "%s", EntryText(entry))
+}
+
+// fieldMap 把交替的键和值转换为字段表，缺少值的键记为 (缺失)
+func fieldMap(lists ...[]interface{}) map[string]interface{} {
+	var fields map[string]interface{}
+	for _, kv := range lists {
+		for i := 0; i < len(kv); i += 2 {
+			if fields == nil {
+				fields = make(map[string]interface{})
+			}
+			key := fmt.Sprint(kv[i])
+			if i+1 < len(kv) {
+				fields[key] = fieldValue(kv[i+1])
+			} else {
+				fields[key] = "(缺失)"
+			}
+		}
+	}
+	return fields
+}
+
+// fieldValue 把 error 转换为错误信息，避免 JSON 格式输出为空对象
+func fieldValue(value interface{}) interface{} {
+	if err, ok := value.(error); ok {
+		return err.Error()
+	}
+	return value
+}
+
+// EntryText 返回不含时间和级别的日志文本：[组件] 消息 键=值...，供不支持结构化日志的记录器显示
+func EntryText(entry Entry) string {
+	var builder strings.Builder
+	if entry.Component != "" {
+		builder.WriteString("[" + entry.Component + "] ")
+	}
+	builder.WriteString(entry.Message)
+	for _, key := range sortedKeys(entry.Fields) {
+		fmt.Fprintf(&builder, " %s=%v", key, entry.Fields[key])
+	}
+	return builder.String()
+}
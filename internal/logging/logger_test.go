@@ -0,0 +1,117 @@
+package logging
+
+import (
+	"fmt"
+	"testing"
+
+	"cursor_history/internal/types"
+)
+
+// recordLogger 记录收到的结构化日志
+type recordLogger struct {
+	entries []Entry
+}
+
+func (l *recordLogger) Log(level string, format string, args ...interface{}) {
+	l.entries = append(l.entries, Entry{Level: level, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *recordLogger) LogEntry(entry Entry) {
+	l.entries = append(l.entries, entry)
+}
+
+func (l *recordLogger) Close() error {
+	return nil
+}
+
+// textLogger 只实现 types.Logger，组件和字段拼接到消息文本中
+type textLogger struct {
+	lines []string
+}
+
+func (l *textLogger) Log(level string, format string, args ...interface{}) {
+	l.lines = append(l.lines, level+" "+fmt.Sprintf(format, args...))
+}
+
+func (l *textLogger) Close() error {
+	return nil
+}
+
+// Log 按级别字符串过滤，低于组件最低级别的日志被丢弃
+func TestLoggerLogFiltersLevel(t *testing.T) {
+	levels, err := ParseLevels("warn,watcher=debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		component string
+		level     string
+		want      bool
+	}{
+		{"", types.LogLevelDebug, false},
+		{"", types.LogLevelInfo, false},
+		{"", "DEFAULT", false},
+		{"", types.LogLevelWarning, true},
+		{"", types.LogLevelError, true},
+		{"sender", types.LogLevelSuccess, false},
+		{"watcher", types.LogLevelDebug, true},
+		{"watcher", "DEFAULT", true},
+	}
+	for _, tt := range tests {
+		out := &recordLogger{}
+		logger := New(out, levels).Component(tt.component)
+		logger.Log(tt.level, "消息 %d", 1)
+		if got := len(out.entries) == 1; got != tt.want {
+			t.Fatalf("组件 %q 的 %s 日志是否记录为 %v，期望 %v", tt.component, tt.level, got, tt.want)
+		}
+		if tt.want && (out.entries[0].Level != tt.level || out.entries[0].Message != "消息 1") {
+			t.Fatalf("记录的日志为 %+v", out.entries[0])
+		}
+	}
+}
+
+// New 包装已有的 *Logger 时保留组件和字段，只替换级别配置
+func TestNewKeepsComponentAndFields(t *testing.T) {
+	out := &recordLogger{}
+	base := New(out, DefaultLevels()).Component("watcher").With("workspace", "demo")
+
+	debug, err := ParseLevels("info,watcher=debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	derived := New(base, debug)
+	derived.Debug("开始监控", "file", "state.vscdb", "err", fmt.Errorf("失败"))
+	base.Debug("默认级别下不记录")
+
+	if len(out.entries) != 1 {
+		t.Fatalf("记录了 %d 条日志，期望 1 条", len(out.entries))
+	}
+	entry := out.entries[0]
+	if entry.Component != "watcher" || entry.Level != types.LogLevelDebug || entry.Message != "开始监控" {
+		t.Fatalf("记录的日志为 %+v", entry)
+	}
+	want := map[string]interface{}{"workspace": "demo", "file": "state.vscdb", "err": "失败"}
+	if len(entry.Fields) != len(want) {
+		t.Fatalf("字段为 %v，期望 %v", entry.Fields, want)
+	}
+	for key, value := range want {
+		if entry.Fields[key] != value {
+			t.Fatalf("字段 %s 为 %v，期望 %v", key, entry.Fields[key], value)
+		}
+	}
+	if From(derived) != derived {
+		t.Fatal("From 应直接返回 *Logger")
+	}
+}
+
+// 输出不支持结构化日志时，组件和字段拼接到消息文本中，缺少值的键记为 (缺失)
+func TestLoggerTextOutput(t *testing.T) {
+	out := &textLogger{}
+	From(out).Component("sender").With("sink", "server").Warn("上传失败", "attempt", 2, "orphan")
+
+	want := types.LogLevelWarning + " [sender] 上传失败 attempt=2 orphan=(缺失) sink=server"
+	if len(out.lines) != 1 || out.lines[0] != want {
+		t.Fatalf("输出为 %q，期望 %q", out.lines, want)
+	}
+}
@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"cursor_history/internal/types"
+)
+
+// MultiLogger 将日志同时写入多个 types.Logger
+type MultiLogger struct {
+	loggers []types.Logger
+}
+
+// Multi 创建同时写入多个日志记录器的 Logger，忽略为 nil 的记录器
+func Multi(loggers ...types.Logger) *MultiLogger {
+	m := &MultiLogger{}
+	for _, logger := range loggers {
+		if logger != nil {
+			m.loggers = append(m.loggers, logger)
+		}
+	}
+	return m
+}
+
+// Log 记录日志
+func (m *MultiLogger) Log(level string, format string, args ...interface{}) {
+	for _, logger := range m.loggers {
+		logger.Log(level, format, args...)
+	}
+}
+
+// LogEntry 记录一条结构化日志，不支持结构化日志的记录器收到拼接后的文本
+func (m *MultiLogger) LogEntry(entry Entry) {
+	for _, logger := range m.loggers {
+		if l, ok := logger.(EntryLogger); ok {
+			l.LogEntry(entry)
+			continue
+		}
+		logger.Log(entry.Level, "%s", EntryText(entry))
+	}
+}
+
+// Close 依次关闭所有日志记录器，返回第一个错误
+func (m *MultiLogger) Close() error {
+	var first error
+	for _, logger := range m.loggers {
+		if err := logger.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
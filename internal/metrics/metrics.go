@@ -0,0 +1,316 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 指标类型
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// DefaultBuckets 默认的耗时直方图分桶（秒）
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// metric 已注册的指标
+type metric interface {
+	name() string
+	write(w io.Writer) error
+}
+
+// 已注册的所有指标，按名称输出
+var (
+	registry      = make(map[string]metric)
+	registryMutex sync.Mutex
+)
+
+// register 注册指标，名称重复时 panic，指标只应在包初始化时创建
+func register(m metric) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if _, ok := registry[m.name()]; ok {
+		panic("指标重复注册: " + m.name())
+	}
+	registry[m.name()] = m
+}
+
+// WriteText 以 Prometheus 文本格式输出所有已注册的指标
+func WriteText(w io.Writer) error {
+	registryMutex.Lock()
+	all := make([]metric, 0, len(registry))
+	for _, m := range registry {
+		all = append(all, m)
+	}
+	registryMutex.Unlock()
+
+	sort.Slice(all, func(i, j int) bool { return all[i].name() < all[j].name() })
+	for _, m := range all {
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// desc 指标的名称、说明和标签
+type desc struct {
+	metricName string
+	help       string
+	typ        string
+	labels     []string
+}
+
+// name 返回指标名称
+func (d desc) name() string {
+	return d.metricName
+}
+
+// key 把标签值拼接为序列的键，标签值数量必须与标签名一致
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("指标 %s 需要 %d 个标签值，实际为 %d 个", d.metricName, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\x00")
+}
+
+// writeHeader 输出 HELP 和 TYPE 行
+func (d desc) writeHeader(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.metricName, escapeHelp(d.help), d.metricName, d.typ)
+	return err
+}
+
+// labelText 返回 {a="1",b="2"} 格式的标签，extra 为附加的标签名和值（如直方图的 le）
+func (d desc) labelText(values []string, extra ...string) string {
+	if len(d.labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+
+	parts := make([]string, 0, len(d.labels)+len(extra)/2)
+	for i, label := range d.labels {
+		parts = append(parts, label+`="`+escapeLabel(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		parts = append(parts, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// series 一组标签值对应的数值
+type series struct {
+	labels []string
+	value  float64
+}
+
+// valueVec 按标签值保存数值，计数器和仪表共用
+type valueVec struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// newValueVec 创建并注册按标签保存数值的指标
+func newValueVec(d desc) *valueVec {
+	v := &valueVec{desc: d, series: make(map[string]*series)}
+	// 没有标签的指标总是输出，从 0 开始
+	if len(d.labels) == 0 {
+		v.series[""] = &series{}
+	}
+	register(v)
+	return v
+}
+
+// update 修改标签值对应的数值
+func (v *valueVec) update(labels []string, fn func(float64) float64) {
+	key := v.key(labels)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s := v.series[key]
+	if s == nil {
+		s = &series{labels: append([]string(nil), labels...)}
+		v.series[key] = s
+	}
+	s.value = fn(s.value)
+}
+
+// write 输出指标的所有序列
+func (v *valueVec) write(w io.Writer) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if err := v.writeHeader(w); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := v.series[key]
+		if _, err := fmt.Fprintf(w, "%s%s %s\n", v.metricName, v.labelText(s.labels), formatValue(s.value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Counter 只增不减的计数器
+type Counter struct {
+	vec *valueVec
+}
+
+// NewCounter 创建并注册计数器，labels 为标签名
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{vec: newValueVec(desc{metricName: name, help: help, typ: typeCounter, labels: labels})}
+}
+
+// Inc 计数加一，labelValues 与创建时的标签名一一对应
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add 计数增加 delta，delta 不能为负数
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic("计数器不能减少: " + c.vec.metricName)
+	}
+	c.vec.update(labelValues, func(v float64) float64 { return v + delta })
+}
+
+// Gauge 可增可减的仪表
+type Gauge struct {
+	vec *valueVec
+}
+
+// NewGauge 创建并注册仪表，labels 为标签名
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{vec: newValueVec(desc{metricName: name, help: help, typ: typeGauge, labels: labels})}
+}
+
+// Set 设置数值
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.vec.update(labelValues, func(float64) float64 { return value })
+}
+
+// Add 数值增加 delta，可为负数
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	g.vec.update(labelValues, func(v float64) float64 { return v + delta })
+}
+
+// histogramSeries 一组标签值对应的分桶计数
+type histogramSeries struct {
+	labels []string
+	counts []uint64 // 与 buckets 对应，不含 +Inf
+	count  uint64
+	sum    float64
+}
+
+// Histogram 直方图，按分桶统计观测值的分布
+type Histogram struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+// NewHistogram 创建并注册直方图，buckets 为升序的分桶上限，为空时使用 DefaultBuckets
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	h := &Histogram{
+		desc:    desc{metricName: name, help: help, typ: typeHistogram, labels: labels},
+		buckets: buckets,
+		series:  make(map[string]*histogramSeries),
+	}
+	register(h)
+	return h
+}
+
+// Observe 记录一个观测值
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	key := h.key(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.series[key]
+	if s == nil {
+		s = &histogramSeries{labels: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, upper := range h.buckets {
+		if value <= upper {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+// write 输出直方图的分桶、总和和数量
+func (h *Histogram) write(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.writeHeader(w); err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := h.series[key]
+		for i, upper := range h.buckets {
+			if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelText(s.labels, "le", formatValue(upper)), s.counts[i]); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelText(s.labels, "le", "+Inf"), s.count); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "%s_sum%s %s\n%s_count%s %d\n",
+			h.metricName, h.labelText(s.labels), formatValue(s.sum),
+			h.metricName, h.labelText(s.labels), s.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatValue 按 Prometheus 文本格式输出数值
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// escapeHelp 转义说明中的反斜杠和换行
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+// escapeLabel 转义标签值中的反斜杠、双引号和换行
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
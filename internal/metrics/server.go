@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cursor_history/internal/types"
+)
+
+// ValidateAddr 校验指标接口的监听地址，只允许 localhost 或回环 IP，指标接口没有认证，避免暴露到网络上
+func ValidateAddr(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("无效的监听地址 %s: %v", addr, err)
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return fmt.Errorf("无效的监听端口: %s", port)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("指标接口只能监听本机回环地址（如 127.0.0.1:9464），当前为: %s", addr)
+}
+
+// Handler 以 Prometheus 文本格式返回所有指标
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		if err := WriteText(&buf); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(buf.Bytes())
+	})
+}
+
+// Server 指标接口
+type Server struct {
+	listener net.Listener
+	server   *http.Server
+}
+
+// Start 在 addr 上提供 /metrics 接口
+func Start(addr string, logger types.Logger) (*Server, error) {
+	if err := ValidateAddr(addr); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("指标接口监听失败: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	s := &Server{
+		listener: listener,
+		server:   &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second},
+	}
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Log(types.LogLevelError, "指标接口停止: %v", err)
+		}
+	}()
+	logger.Log(types.LogLevelInfo, "指标接口已启动: http://%s/metrics", listener.Addr())
+	return s, nil
+}
+
+// Addr 返回实际监听的地址
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close 停止指标接口
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
@@ -0,0 +1,208 @@
+// Package policy 根据工作区目录和 Git 远程地址决定是否允许上传
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 规则动作
+const (
+	ActionAllow = "allow"
+	ActionDeny  = "deny"
+)
+
+// 正则表达式模式的前缀，其余模式按 glob 处理
+const regexPrefix = "re:"
+
+// Rule 工作区上传规则，Workspace 和 Remote 都指定时必须同时匹配
+// 模式默认为 glob（* 不跨越 /，** 可跨越 /，? 匹配单个字符，忽略大小写），以 re: 开头时为正则表达式
+type Rule struct {
+	Action    string `json:"action"`
+	Workspace string `json:"workspace,omitempty"` // 匹配工作区目录
+	Remote    string `json:"remote,omitempty"`    // 匹配 Git 远程地址
+}
+
+// String 返回规则的可读描述
+func (r Rule) String() string {
+	var parts []string
+	if r.Workspace != "" {
+		parts = append(parts, "workspace="+r.Workspace)
+	}
+	if r.Remote != "" {
+		parts = append(parts, "remote="+r.Remote)
+	}
+	return r.Action + " " + strings.Join(parts, " ")
+}
+
+// Config 工作区上传规则配置
+type Config struct {
+	Default string `json:"default,omitempty"` // 没有规则匹配时的动作，默认为 allow
+	Rules   []Rule `json:"rules"`
+}
+
+// Decision 规则判定结果
+type Decision struct {
+	Allowed bool
+	Rule    int // 命中的规则序号（从 1 开始），0 表示使用默认动作
+}
+
+// Policy 编译后的上传规则，按顺序匹配，第一条匹配的规则生效
+type Policy struct {
+	config       Config
+	rules        []compiledRule
+	defaultAllow bool
+}
+
+// compiledRule 编译后的规则
+type compiledRule struct {
+	allow     bool
+	workspace *regexp.Regexp
+	remote    *regexp.Regexp
+}
+
+// Parse 解析 JSON 格式的规则配置，空字符串表示允许所有工作区
+func Parse(value string) (Config, error) {
+	var config Config
+	if value == "" {
+		return config, nil
+	}
+	if err := json.Unmarshal([]byte(value), &config); err != nil {
+		return config, fmt.Errorf("解析工作区规则失败: %v", err)
+	}
+	return config, nil
+}
+
+// Compile 编译规则配置
+func Compile(config Config) (*Policy, error) {
+	p := &Policy{config: config, defaultAllow: true}
+	switch config.Default {
+	case "", ActionAllow:
+	case ActionDeny:
+		p.defaultAllow = false
+	default:
+		return nil, fmt.Errorf("无效的默认动作: %s，可用: %s、%s", config.Default, ActionAllow, ActionDeny)
+	}
+
+	for i, rule := range config.Rules {
+		compiled, err := compileRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 条规则无效: %v", i+1, err)
+		}
+		p.rules = append(p.rules, compiled)
+	}
+	return p, nil
+}
+
+// Load 解析并编译 JSON 格式的规则配置
+func Load(value string) (*Policy, error) {
+	config, err := Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	return Compile(config)
+}
+
+// Validate 校验 JSON 格式的规则配置
+func Validate(value string) error {
+	_, err := Load(value)
+	return err
+}
+
+// DenyAll 返回拒绝所有工作区的规则，用于规则配置无效时
+func DenyAll() *Policy {
+	return &Policy{config: Config{Default: ActionDeny}}
+}
+
+// Config 返回规则配置
+func (p *Policy) Config() Config {
+	return p.config
+}
+
+// Evaluate 判定工作区是否允许上传
+func (p *Policy) Evaluate(workspace, remote string) Decision {
+	workspace = strings.ReplaceAll(workspace, `\`, "/")
+	for i, rule := range p.rules {
+		if rule.workspace != nil && !rule.workspace.MatchString(workspace) {
+			continue
+		}
+		if rule.remote != nil && !rule.remote.MatchString(remote) {
+			continue
+		}
+		return Decision{Allowed: rule.allow, Rule: i + 1}
+	}
+	return Decision{Allowed: p.defaultAllow}
+}
+
+// compileRule 编译单条规则
+func compileRule(rule Rule) (compiledRule, error) {
+	var compiled compiledRule
+	switch rule.Action {
+	case ActionAllow:
+		compiled.allow = true
+	case ActionDeny:
+	default:
+		return compiled, fmt.Errorf("无效的动作: %q，可用: %s、%s", rule.Action, ActionAllow, ActionDeny)
+	}
+	if rule.Workspace == "" && rule.Remote == "" {
+		return compiled, fmt.Errorf("未指定 workspace 或 remote")
+	}
+
+	var err error
+	if rule.Workspace != "" {
+		if compiled.workspace, err = compilePattern(rule.Workspace); err != nil {
+			return compiled, err
+		}
+	}
+	if rule.Remote != "" {
+		if compiled.remote, err = compilePattern(rule.Remote); err != nil {
+			return compiled, err
+		}
+	}
+	return compiled, nil
+}
+
+// compilePattern 编译 glob 或正则表达式模式
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if strings.HasPrefix(pattern, regexPrefix) {
+		re, err := regexp.Compile(strings.TrimPrefix(pattern, regexPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("正则表达式 %s 无效: %v", pattern, err)
+		}
+		return re, nil
+	}
+	return regexp.MustCompile(globToRegexp(pattern)), nil
+}
+
+// globToRegexp 把 glob 模式转换为忽略大小写、完整匹配的正则表达式
+func globToRegexp(glob string) string {
+	runes := []rune(strings.ReplaceAll(glob, `\`, "/"))
+
+	var builder strings.Builder
+	builder.WriteString("(?i)^")
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; c {
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				i++
+				if i+1 < len(runes) && runes[i+1] == '/' {
+					// **/ 匹配零个或多个目录
+					i++
+					builder.WriteString("(?:.*/)?")
+				} else {
+					builder.WriteString(".*")
+				}
+			} else {
+				builder.WriteString("[^/]*")
+			}
+		case '?':
+			builder.WriteString("[^/]")
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	builder.WriteString("$")
+	return builder.String()
+}
@@ -0,0 +1,329 @@
+// Package redact 在提示词离开本机前脱敏其中的密钥、令牌等敏感信息
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// 内置检测器名称
+const (
+	DetectorPrivateKey       = "private_key"
+	DetectorJWT              = "jwt"
+	DetectorAWSAccessKey     = "aws_access_key"
+	DetectorAWSSecretKey     = "aws_secret_key"
+	DetectorGitHubToken      = "github_token"
+	DetectorConnectionString = "connection_string"
+	DetectorEmail            = "email"
+	DetectorHighEntropy      = "high_entropy"
+)
+
+// 高熵字符串判定阈值（每字符比特数）
+const highEntropyThreshold = 4.0
+
+// Rule 用户自定义的脱敏规则
+type Rule struct {
+	Name    string `json:"name"`    // 规则名称，用于占位符和日志
+	Pattern string `json:"pattern"` // 正则表达式，包含捕获组时只替换第一个捕获组
+}
+
+// Hit 一次脱敏中某个检测器的命中次数
+type Hit struct {
+	Detector string
+	Count    int
+}
+
+// detector 脱敏检测器
+type detector struct {
+	name  string
+	re    *regexp.Regexp
+	group int                     // 要替换的捕获组，0 表示整个匹配
+	check func(match string) bool // 额外校验，为 nil 时总是替换
+}
+
+// builtinDetectors 内置检测器，按顺序执行，范围大的规则在前，避免被拆成多段
+var builtinDetectors = []detector{
+	{
+		name: DetectorPrivateKey,
+		re:   regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----[\s\S]*?(?:-----END [A-Z0-9 ]*PRIVATE KEY-----|$)`),
+	},
+	{
+		name: DetectorJWT,
+		re:   regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]+`),
+	},
+	{
+		name: DetectorAWSAccessKey,
+		re:   regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[0-9A-Z]{16}\b`),
+	},
+	{
+		name:  DetectorAWSSecretKey,
+		re:    regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})`),
+		group: 1,
+	},
+	{
+		name: DetectorGitHubToken,
+		re:   regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`),
+	},
+	{
+		name:  DetectorConnectionString,
+		re:    regexp.MustCompile(`\b[A-Za-z][A-Za-z0-9+.-]*://[^\s:/@]+:([^\s@/]+)@`),
+		group: 1,
+	},
+	{
+		name: DetectorEmail,
+		re:   regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`),
+	},
+	{
+		// 不包含 /，路径中的各级目录分别判断，避免 internal/upload/sinkServer2Test.go 这样的路径被当作密钥
+		name:  DetectorHighEntropy,
+		re:    regexp.MustCompile(`[A-Za-z0-9+_-]{24,}={0,2}`),
+		check: isHighEntropy,
+	},
+}
+
+// Detectors 返回所有内置检测器名称
+func Detectors() []string {
+	names := make([]string, len(builtinDetectors))
+	for i, d := range builtinDetectors {
+		names[i] = d.name
+	}
+	return names
+}
+
+// Placeholder 返回检测器对应的占位符，如 [REDACTED:AWS_ACCESS_KEY]
+func Placeholder(name string) string {
+	return "[REDACTED:" + strings.ToUpper(name) + "]"
+}
+
+// Redactor 脱敏器，可并发使用，并累计各检测器的命中次数
+type Redactor struct {
+	detectors []detector
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// New 创建脱敏器，rules 为用户自定义规则，在内置检测器之前执行；disabled 为要关闭的内置检测器
+func New(rules []Rule, disabled []string) (*Redactor, error) {
+	off := make(map[string]bool, len(disabled))
+	for _, name := range disabled {
+		if !isBuiltin(name) {
+			return nil, fmt.Errorf("未知的内置检测器: %s", name)
+		}
+		off[name] = true
+	}
+
+	r := &Redactor{counts: make(map[string]int)}
+	for _, rule := range rules {
+		d, err := compileRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		r.detectors = append(r.detectors, d)
+	}
+	for _, d := range builtinDetectors {
+		if !off[d.name] {
+			r.detectors = append(r.detectors, d)
+		}
+	}
+	return r, nil
+}
+
+// Default 创建只使用内置检测器的脱敏器
+func Default() *Redactor {
+	r, _ := New(nil, nil)
+	return r
+}
+
+// Redact 脱敏文本，返回脱敏后的文本和各检测器的命中次数
+func (r *Redactor) Redact(text string) (string, []Hit) {
+	var hits []Hit
+	for _, d := range r.detectors {
+		var n int
+		text, n = d.replace(text)
+		if n > 0 {
+			hits = append(hits, Hit{Detector: d.name, Count: n})
+		}
+	}
+
+	if len(hits) > 0 {
+		r.mu.Lock()
+		for _, hit := range hits {
+			r.counts[hit.Detector] += hit.Count
+		}
+		r.mu.Unlock()
+	}
+	return text, hits
+}
+
+// Counts 返回启动以来各检测器的累计命中次数
+func (r *Redactor) Counts() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := make(map[string]int, len(r.counts))
+	for name, n := range r.counts {
+		counts[name] = n
+	}
+	return counts
+}
+
+// FormatHits 把命中次数格式化为日志文本，如 "aws_access_key×1, email×2"，不包含敏感内容
+func FormatHits(hits []Hit) string {
+	parts := make([]string, len(hits))
+	for i, hit := range hits {
+		parts[i] = fmt.Sprintf("%s×%d", hit.Detector, hit.Count)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// FormatCounts 把累计命中次数格式化为日志文本
+func FormatCounts(counts map[string]int) string {
+	hits := make([]Hit, 0, len(counts))
+	for name, n := range counts {
+		hits = append(hits, Hit{Detector: name, Count: n})
+	}
+	return FormatHits(hits)
+}
+
+// ParseRules 解析 JSON 格式的自定义规则，空字符串表示没有自定义规则
+func ParseRules(value string) ([]Rule, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return nil, fmt.Errorf("解析脱敏规则失败: %v", err)
+	}
+	for _, rule := range rules {
+		if _, err := compileRule(rule); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// ParseDisabled 解析逗号分隔的要关闭的内置检测器列表
+func ParseDisabled(value string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !isBuiltin(name) {
+			return nil, fmt.Errorf("未知的内置检测器: %s，可用: %s", name, strings.Join(Detectors(), ", "))
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// ValidateRules 校验自定义规则配置
+func ValidateRules(value string) error {
+	_, err := ParseRules(value)
+	return err
+}
+
+// ValidateDisabled 校验要关闭的内置检测器配置
+func ValidateDisabled(value string) error {
+	_, err := ParseDisabled(value)
+	return err
+}
+
+// compileRule 编译自定义规则
+func compileRule(rule Rule) (detector, error) {
+	if rule.Name == "" {
+		return detector{}, fmt.Errorf("脱敏规则缺少名称: %s", rule.Pattern)
+	}
+	if isBuiltin(rule.Name) {
+		return detector{}, fmt.Errorf("脱敏规则名称与内置检测器重复: %s", rule.Name)
+	}
+	re, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return detector{}, fmt.Errorf("脱敏规则 %s 的正则表达式无效: %v", rule.Name, err)
+	}
+
+	d := detector{name: rule.Name, re: re}
+	if re.NumSubexp() > 0 {
+		d.group = 1
+	}
+	return d, nil
+}
+
+// isBuiltin 判断是否为内置检测器名称
+func isBuiltin(name string) bool {
+	for _, d := range builtinDetectors {
+		if d.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// replace 把匹配内容替换为占位符，返回替换后的文本和替换次数
+func (d detector) replace(text string) (string, int) {
+	matches := d.re.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text, 0
+	}
+
+	placeholder := Placeholder(d.name)
+	var builder strings.Builder
+	last, n := 0, 0
+	for _, m := range matches {
+		start, end := m[2*d.group], m[2*d.group+1]
+		if start < 0 || start == end {
+			continue
+		}
+		if d.check != nil && !d.check(text[start:end]) {
+			continue
+		}
+		builder.WriteString(text[last:start])
+		builder.WriteString(placeholder)
+		last = end
+		n++
+	}
+	if n == 0 {
+		return text, 0
+	}
+	builder.WriteString(text[last:])
+	return builder.String(), n
+}
+
+// isHighEntropy 判断字符串是否像随机生成的密钥：同时包含大小写字母和数字，且香农熵足够高
+// 纯十六进制的哈希值和普通路径、标识符不会命中
+func isHighEntropy(s string) bool {
+	var upper, lower, digit bool
+	freq := make(map[rune]int)
+	for _, r := range s {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+		freq[r]++
+	}
+	if !upper || !lower || !digit {
+		return false
+	}
+
+	var entropy float64
+	length := float64(len(s))
+	for _, count := range freq {
+		p := float64(count) / length
+		entropy -= p * math.Log2(p)
+	}
+	return entropy >= highEntropyThreshold
+}
@@ -0,0 +1,47 @@
+package source
+
+import (
+	"encoding/json"
+
+	"cursor_history/internal/conversation"
+	"cursor_history/internal/discovery"
+)
+
+// CursorName Cursor 来源名称
+const CursorName = "cursor"
+
+func init() {
+	Register(&Source{
+		Name:   CursorName,
+		AppDir: discovery.CursorAppDir,
+		Keys: []Key{
+			{Name: "aiService.prompts", Parse: parseCursorPrompts},
+		},
+		ConversationKeys: []ConversationKey{
+			{Name: conversation.ChatDataKey, Parse: parseCursorChat},
+			{Name: conversation.ComposerDataKey, Parse: conversation.ParseComposers},
+		},
+	})
+}
+
+// parseCursorChat 解析聊天面板数据，聊天数据完整保存在工作区中，不需要读取全局存储
+func parseCursorChat(value string, _ conversation.Lookup) ([]conversation.Conversation, error) {
+	return conversation.ParseChatData(value)
+}
+
+// parseCursorPrompts 解析 aiService.prompts，格式为 [{"text": "...", "commandType": 4}]
+func parseCursorPrompts(value string) ([]Prompt, error) {
+	var items []struct {
+		Text        string `json:"text"`
+		CommandType int    `json:"commandType"`
+	}
+	if err := json.Unmarshal([]byte(value), &items); err != nil {
+		return nil, err
+	}
+
+	prompts := make([]Prompt, 0, len(items))
+	for _, item := range items {
+		prompts = append(prompts, Prompt{Text: item.Text, CommandType: item.CommandType})
+	}
+	return prompts, nil
+}
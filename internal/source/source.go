@@ -0,0 +1,128 @@
+package source
+
+import (
+	"path/filepath"
+	"strings"
+
+	"cursor_history/internal/conversation"
+	"cursor_history/internal/discovery"
+)
+
+// Prompt 从编辑器存储中提取出的一条提示词
+type Prompt struct {
+	Text        string
+	CommandType int
+}
+
+// Key ItemTable 中保存 AI 提示词的键及其解析方式
+type Key struct {
+	Name  string
+	Parse func(value string) ([]Prompt, error)
+}
+
+// ConversationKey ItemTable 中保存完整对话的键及其解析方式，lookup 用于读取全局 cursorDiskKV 表
+type ConversationKey struct {
+	Name  string
+	Parse func(value string, lookup conversation.Lookup) ([]conversation.Conversation, error)
+}
+
+// Source 一个 VS Code 系列编辑器来源
+type Source struct {
+	Name             string            // 上传记录中的编辑器名称
+	AppDir           string            // 编辑器在用户配置目录下使用的目录名
+	Keys             []Key             // ItemTable 中保存提示词的键
+	ConversationKeys []ConversationKey // ItemTable 中保存完整对话的键
+}
+
+// Root 某个来源实际存在的 workspaceStorage 目录
+type Root struct {
+	Source *Source
+	Path   string
+}
+
+// 已注册的来源，按注册顺序排列
+var registry []*Source
+
+// Register 注册编辑器来源，同名来源会被替换
+func Register(src *Source) {
+	for i, existing := range registry {
+		if existing.Name == src.Name {
+			registry[i] = src
+			return
+		}
+	}
+	registry = append(registry, src)
+}
+
+// All 返回所有已注册的来源
+func All() []*Source {
+	return append([]*Source(nil), registry...)
+}
+
+// Lookup 按名称查找来源，找不到时返回 nil
+func Lookup(name string) *Source {
+	for _, src := range registry {
+		if src.Name == name {
+			return src
+		}
+	}
+	return nil
+}
+
+// Key 按名称查找来源中的键，找不到时返回 nil
+func (s *Source) Key(name string) *Key {
+	for i := range s.Keys {
+		if s.Keys[i].Name == name {
+			return &s.Keys[i]
+		}
+	}
+	return nil
+}
+
+// ForPath 根据 User/workspaceStorage 或 User/globalStorage 上一级的编辑器目录名判断来源，无法判断时视为 Cursor
+// 只比较这一级目录，上级目录名恰好与其他编辑器的目录名相同时不会判断错误
+func ForPath(path string) *Source {
+	segments := strings.Split(filepath.ToSlash(filepath.Clean(path)), "/")
+	for i := len(segments) - 2; i >= 1; i-- {
+		storage := segments[i+1]
+		if !strings.EqualFold(segments[i], "User") ||
+			(!strings.EqualFold(storage, "workspaceStorage") && !strings.EqualFold(storage, "globalStorage")) {
+			continue
+		}
+		for _, src := range registry {
+			if strings.EqualFold(segments[i-1], src.AppDir) {
+				return src
+			}
+		}
+		break
+	}
+	return Lookup(CursorName)
+}
+
+// Roots 解析所有来源实际存在的 workspaceStorage 目录
+// override（或 CURSOR_HISTORY_STORAGE 环境变量）不为空时只使用显式指定的目录，并根据路径判断所属来源
+func Roots(override string) []Root {
+	var roots []Root
+	if override = discovery.Override(override); override != "" {
+		for _, path := range discovery.Resolve("", override) {
+			roots = append(roots, Root{Source: ForPath(path), Path: path})
+		}
+		return roots
+	}
+
+	for _, src := range registry {
+		for _, path := range discovery.Resolve(src.AppDir, "") {
+			roots = append(roots, Root{Source: src, Path: path})
+		}
+	}
+	return roots
+}
+
+// CandidatePaths 返回所有来源的候选目录，用于提示用户
+func CandidatePaths() []string {
+	var paths []string
+	for _, src := range registry {
+		paths = append(paths, discovery.Candidates(src.AppDir)...)
+	}
+	return paths
+}
@@ -0,0 +1,27 @@
+package source
+
+import "testing"
+
+func TestForPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/home/u/.config/Cursor/User/workspaceStorage", CursorName},
+		{"/home/u/.config/Cursor/User/workspaceStorage/abc/state.vscdb", CursorName},
+		{"/home/u/.config/Code - Insiders/User/workspaceStorage", VSCodeInsidersName},
+		{"/Users/u/Library/Application Support/Windsurf/User/globalStorage/state.vscdb", WindsurfName},
+		{"/home/u/.config/vscodium/user/workspacestorage", VSCodiumName},
+		// 上级目录名与其他编辑器相同时按 User 上一级的目录判断
+		{"/home/u/cursor/.config/Code - Insiders/User/workspaceStorage/abc/state.vscdb", VSCodeInsidersName},
+		{"/home/u/Windsurf/.config/Cursor/User/workspaceStorage", CursorName},
+		// 无法判断时视为 Cursor
+		{"/home/u/Windsurf/backup/workspaceStorage", CursorName},
+		{"/data/storage", CursorName},
+	}
+	for _, tt := range tests {
+		if got := ForPath(tt.path); got == nil || got.Name != tt.want {
+			t.Fatalf("ForPath(%q) 判断为 %v，期望 %s", tt.path, got, tt.want)
+		}
+	}
+}
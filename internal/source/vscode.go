@@ -0,0 +1,91 @@
+package source
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// VS Code 系列编辑器的来源名称
+const (
+	VSCodeInsidersName = "vscode-insiders"
+	VSCodiumName       = "vscodium"
+	WindsurfName       = "windsurf"
+)
+
+// VS Code 聊天视图保存提示词的键，VS Code Insiders、VSCodium 和 Windsurf 共用这套布局
+var vscodeChatKeys = []Key{
+	{Name: "interactive.sessions", Parse: parseInteractiveSessions},
+	{Name: "memento/interactive-session", Parse: parseInteractiveHistory},
+}
+
+func init() {
+	Register(&Source{Name: VSCodeInsidersName, AppDir: "Code - Insiders", Keys: vscodeChatKeys})
+	Register(&Source{Name: WindsurfName, AppDir: "Windsurf", Keys: vscodeChatKeys})
+	Register(&Source{Name: VSCodiumName, AppDir: "VSCodium", Keys: vscodeChatKeys})
+}
+
+// parseInteractiveSessions 解析 interactive.sessions，格式为 [{"requests": [{"message": {"text": "..."}}]}]
+func parseInteractiveSessions(value string) ([]Prompt, error) {
+	var sessions []struct {
+		Requests []struct {
+			Message struct {
+				Text string `json:"text"`
+			} `json:"message"`
+		} `json:"requests"`
+	}
+	if err := json.Unmarshal([]byte(value), &sessions); err != nil {
+		return nil, err
+	}
+
+	var prompts []Prompt
+	for _, session := range sessions {
+		for _, request := range session.Requests {
+			if request.Message.Text != "" {
+				prompts = append(prompts, Prompt{Text: request.Message.Text})
+			}
+		}
+	}
+	return prompts, nil
+}
+
+// parseInteractiveHistory 解析 memento/interactive-session 中的输入历史
+// 格式为 {"history": {"<provider>": ["...", {"inputText": "..."}]}}，旧版本为字符串，新版本为对象
+func parseInteractiveHistory(value string) ([]Prompt, error) {
+	var memento struct {
+		History map[string][]json.RawMessage `json:"history"`
+	}
+	if err := json.Unmarshal([]byte(value), &memento); err != nil {
+		return nil, err
+	}
+
+	// 按提供方名称排序，保证提取顺序稳定
+	providers := make([]string, 0, len(memento.History))
+	for provider := range memento.History {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+
+	var prompts []Prompt
+	for _, provider := range providers {
+		for _, raw := range memento.History[provider] {
+			var text string
+			if err := json.Unmarshal(raw, &text); err != nil {
+				var entry struct {
+					InputText string `json:"inputText"`
+					Text      string `json:"text"`
+				}
+				if err := json.Unmarshal(raw, &entry); err != nil {
+					continue
+				}
+				text = entry.InputText
+				if text == "" {
+					text = entry.Text
+				}
+			}
+			if text != "" {
+				prompts = append(prompts, Prompt{Text: text})
+			}
+		}
+	}
+	return prompts, nil
+}
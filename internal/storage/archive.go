@@ -0,0 +1,329 @@
+package storage
+
+import (
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ArchivedPrompt 本地归档的提示词
+type ArchivedPrompt struct {
+	ID          int64  `json:"id"`
+	Text        string `json:"text"`
+	CommandType int    `json:"commandType"`
+	Editor      string `json:"editor"`
+	Workspace   string `json:"workspace"`
+	GitRemote   string `json:"gitRemote,omitempty"`
+	GitCommit   string `json:"gitCommit,omitempty"`
+	GitBranch   string `json:"gitBranch,omitempty"`
+	SourceFile  string `json:"sourceFile"`
+	Timestamp   int64  `json:"timestamp"` // 提示词所在文件的修改时间（秒）
+	CreatedAt   int64  `json:"createdAt"` // 归档时间（秒）
+}
+
+// SearchFilter 搜索过滤条件，零值表示不限制
+type SearchFilter struct {
+	Workspace    string    // 工作区路径包含该字符串
+	Editor       string    // 编辑器名称
+	CommandTypes []int     // 命令类型
+	Since        time.Time // 提示词时间不早于
+	Until        time.Time // 提示词时间早于
+	Limit        int       // 最多返回条数，默认 50，负数表示不限制
+}
+
+// defaultSearchLimit 默认最多返回的搜索结果数
+const defaultSearchLimit = 50
+
+// initFTS 创建提示词全文索引，FTS5 是否可用取决于编译选项，因此不放在版本升级脚本中
+// 使用 trigram 分词以支持中文子串搜索；当前 sqlite 未编译 FTS5 时返回 false，搜索退化为 LIKE 匹配
+func initFTS(db *sql.DB) (bool, error) {
+	var available bool
+	if err := db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&available); err != nil {
+		return false, fmt.Errorf("检查全文索引支持失败: %v", err)
+	}
+	if !available {
+		// 数据库可能由支持 FTS5 的版本创建过索引，其触发器会让归档写入失败，删除后由支持 FTS5 的版本重建索引
+		_, err := db.Exec(`
+			DROP TRIGGER IF EXISTS prompts_ai;
+			DROP TRIGGER IF EXISTS prompts_ad;
+			DROP TRIGGER IF EXISTS prompts_au;
+		`)
+		if err != nil {
+			return false, fmt.Errorf("删除全文索引触发器失败: %v", err)
+		}
+		return false, nil
+	}
+
+	// 没有触发器时索引不存在或未与归档表同步，创建后需要重建
+	var synced bool
+	err := db.QueryRow(`SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'trigger' AND name = 'prompts_ai'`).Scan(&synced)
+	if err != nil {
+		return false, fmt.Errorf("检查全文索引失败: %v", err)
+	}
+
+	_, err = db.Exec(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS prompts_fts USING fts5(
+			text, workspace,
+			content='prompts', content_rowid='id',
+			tokenize='trigram'
+		)
+	`)
+	if err != nil {
+		return false, fmt.Errorf("创建全文索引失败: %v", err)
+	}
+
+	// 使用触发器保持全文索引与归档表同步
+	_, err = db.Exec(`
+		CREATE TRIGGER IF NOT EXISTS prompts_ai AFTER INSERT ON prompts BEGIN
+			INSERT INTO prompts_fts(rowid, text, workspace) VALUES (new.id, new.text, new.workspace);
+		END;
+		CREATE TRIGGER IF NOT EXISTS prompts_ad AFTER DELETE ON prompts BEGIN
+			INSERT INTO prompts_fts(prompts_fts, rowid, text, workspace) VALUES ('delete', old.id, old.text, old.workspace);
+		END;
+		CREATE TRIGGER IF NOT EXISTS prompts_au AFTER UPDATE ON prompts BEGIN
+			INSERT INTO prompts_fts(prompts_fts, rowid, text, workspace) VALUES ('delete', old.id, old.text, old.workspace);
+			INSERT INTO prompts_fts(rowid, text, workspace) VALUES (new.id, new.text, new.workspace);
+		END;
+	`)
+	if err != nil {
+		return false, fmt.Errorf("创建全文索引触发器失败: %v", err)
+	}
+
+	if !synced {
+		if _, err := db.Exec(`INSERT INTO prompts_fts(prompts_fts) VALUES ('rebuild')`); err != nil {
+			return false, fmt.Errorf("重建全文索引失败: %v", err)
+		}
+	}
+	return true, nil
+}
+
+// archiveHash 归档去重键，同一工作区同一编辑器中相同的提示词只保存一次
+func archiveHash(p ArchivedPrompt) string {
+	hash := md5.Sum([]byte(p.Editor + "\x00" + p.Workspace + "\x00" + p.Text))
+	return hex.EncodeToString(hash[:])
+}
+
+// Archive 提示词归档库，可以是配置数据库中的归档表，也可以是只包含归档表的独立数据库（sqlite 上传目标）
+type Archive struct {
+	db  *sql.DB
+	fts bool // 是否支持 FTS5 全文索引
+}
+
+// archiveSchema 独立归档库的表结构，与升级脚本 0002_archive_outbox.sql 中的归档表一致
+const archiveSchema = `
+	CREATE TABLE IF NOT EXISTS prompts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		hash TEXT NOT NULL UNIQUE,
+		text TEXT NOT NULL,
+		command_type INTEGER NOT NULL DEFAULT 0,
+		editor TEXT NOT NULL DEFAULT '',
+		workspace TEXT NOT NULL DEFAULT '',
+		git_remote TEXT NOT NULL DEFAULT '',
+		git_commit TEXT NOT NULL DEFAULT '',
+		git_branch TEXT NOT NULL DEFAULT '',
+		source_file TEXT NOT NULL DEFAULT '',
+		timestamp INTEGER NOT NULL DEFAULT 0,
+		created_at INTEGER NOT NULL
+	)
+`
+
+// OpenArchive 打开提示词归档库，只创建归档表和全文索引，不执行配置数据库的升级脚本
+// 也可以打开配置数据库，只读写其中的归档表
+func OpenArchive(path string) (*Archive, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建归档目录失败: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("打开归档库失败: %v", err)
+	}
+	if _, err := db.Exec(archiveSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("创建归档表失败: %v", err)
+	}
+
+	fts, err := initFTS(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Archive{db: db, fts: fts}, nil
+}
+
+// Close 关闭归档库
+func (a *Archive) Close() error {
+	return a.db.Close()
+}
+
+// FullTextSearch 返回是否支持 FTS5 全文索引，未使用 sqlite_fts5 编译时搜索使用 LIKE 匹配
+func (a *Archive) FullTextSearch() bool {
+	return a.fts
+}
+
+// FullTextSearch 返回配置数据库中的归档是否支持全文索引
+func (c *ConfigManager) FullTextSearch() bool {
+	return c.fts
+}
+
+// ArchivePrompt 保存提示词到配置数据库中的本地归档，已存在时返回 false
+func (c *ConfigManager) ArchivePrompt(p ArchivedPrompt) (bool, error) {
+	archive := Archive{db: c.db, fts: c.fts}
+	return archive.ArchivePrompt(p)
+}
+
+// ArchivePrompt 保存提示词到本地归档，已存在时返回 false
+func (a *Archive) ArchivePrompt(p ArchivedPrompt) (bool, error) {
+	if p.CreatedAt == 0 {
+		p.CreatedAt = time.Now().Unix()
+	}
+
+	result, err := a.db.Exec(`
+		INSERT OR IGNORE INTO prompts (
+			hash, text, command_type, editor, workspace,
+			git_remote, git_commit, git_branch, source_file, timestamp, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, archiveHash(p), p.Text, p.CommandType, p.Editor, p.Workspace,
+		p.GitRemote, p.GitCommit, p.GitBranch, p.SourceFile, p.Timestamp, p.CreatedAt)
+	if err != nil {
+		return false, fmt.Errorf("归档提示词失败: %v", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("归档提示词失败: %v", err)
+	}
+	return affected > 0, nil
+}
+
+// Search 搜索本地归档的提示词，query 为空时按时间倒序列出
+// 支持全文索引时每个关键词使用 FTS5 匹配，否则（或关键词少于 3 个字符时）使用 LIKE 匹配
+func (a *Archive) Search(query string, filter SearchFilter) ([]ArchivedPrompt, error) {
+	var (
+		from       = "prompts p"
+		conditions []string
+		args       []interface{}
+		orderBy    = "p.timestamp DESC, p.id DESC"
+	)
+
+	terms := strings.Fields(query)
+	if len(terms) > 0 {
+		var ftsTerms []string
+		for _, term := range terms {
+			if a.fts && utf8.RuneCountInString(term) >= 3 {
+				ftsTerms = append(ftsTerms, `"`+strings.ReplaceAll(term, `"`, `""`)+`"`)
+				continue
+			}
+			conditions = append(conditions, "p.text LIKE ? ESCAPE '\\'")
+			args = append(args, "%"+escapeLike(term)+"%")
+		}
+
+		if len(ftsTerms) > 0 {
+			from = "prompts_fts f JOIN prompts p ON p.id = f.rowid"
+			conditions = append([]string{"prompts_fts MATCH ?"}, conditions...)
+			args = append([]interface{}{"text : (" + strings.Join(ftsTerms, " AND ") + ")"}, args...)
+			orderBy = "f.rank, p.timestamp DESC"
+		}
+	}
+
+	if filter.Workspace != "" {
+		conditions = append(conditions, "p.workspace LIKE ? ESCAPE '\\'")
+		args = append(args, "%"+escapeLike(filter.Workspace)+"%")
+	}
+	if filter.Editor != "" {
+		conditions = append(conditions, "p.editor = ?")
+		args = append(args, filter.Editor)
+	}
+	if len(filter.CommandTypes) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(filter.CommandTypes)), ",")
+		conditions = append(conditions, "p.command_type IN ("+placeholders+")")
+		for _, t := range filter.CommandTypes {
+			args = append(args, t)
+		}
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "p.timestamp >= ?")
+		args = append(args, filter.Since.Unix())
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "p.timestamp < ?")
+		args = append(args, filter.Until.Unix())
+	}
+
+	// SQLite 中 LIMIT 为负数时不限制条数
+	limit := filter.Limit
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+
+	sqlText := `
+		SELECT p.id, p.text, p.command_type, p.editor, p.workspace,
+			p.git_remote, p.git_commit, p.git_branch, p.source_file, p.timestamp, p.created_at
+		FROM ` + from
+	if len(conditions) > 0 {
+		sqlText += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	sqlText += " ORDER BY " + orderBy + " LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := a.db.Query(sqlText, args...)
+	if err != nil {
+		return nil, fmt.Errorf("搜索提示词失败: %v", err)
+	}
+	defer rows.Close()
+
+	var results []ArchivedPrompt
+	for rows.Next() {
+		var p ArchivedPrompt
+		if err := rows.Scan(&p.ID, &p.Text, &p.CommandType, &p.Editor, &p.Workspace,
+			&p.GitRemote, &p.GitCommit, &p.GitBranch, &p.SourceFile, &p.Timestamp, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("读取搜索结果失败: %v", err)
+		}
+		results = append(results, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取搜索结果失败: %v", err)
+	}
+	return results, nil
+}
+
+// Match 判断提示词是否满足过滤条件（不含 Limit），用于过滤未归档的提示词，与 Search 的条件一致
+func (f SearchFilter) Match(p ArchivedPrompt) bool {
+	if f.Workspace != "" && !strings.Contains(strings.ToLower(p.Workspace), strings.ToLower(f.Workspace)) {
+		return false
+	}
+	if f.Editor != "" && p.Editor != f.Editor {
+		return false
+	}
+	if len(f.CommandTypes) > 0 {
+		found := false
+		for _, t := range f.CommandTypes {
+			if p.CommandType == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && p.Timestamp < f.Since.Unix() {
+		return false
+	}
+	if !f.Until.IsZero() && p.Timestamp >= f.Until.Unix() {
+		return false
+	}
+	return true
+}
+
+// escapeLike 转义 LIKE 模式中的通配符
+func escapeLike(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(s)
+}
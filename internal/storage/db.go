@@ -6,14 +6,38 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"time"
 
 	_ "github.com/mattn/go-sqlite3" // 导入 sqlite3 驱动
 )
 
+// DefaultConfigDir 返回应用配置目录（用户配置目录下的 CursorHistory），不存在时自动创建
+func DefaultConfigDir() (string, error) {
+	appDataDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("获取配置目录失败: %v", err)
+	}
+
+	configDir := filepath.Join(appDataDir, "CursorHistory")
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return "", fmt.Errorf("创建配置目录失败: %v", err)
+	}
+	return configDir, nil
+}
+
+// DefaultDBPath 返回默认的配置数据库路径
+func DefaultDBPath() (string, error) {
+	configDir, err := DefaultConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "config.db"), nil
+}
+
 // ConfigManager 配置管理器
 type ConfigManager struct {
 	db     *sql.DB
+	dir    string // 数据库所在目录
+	fts    bool   // 是否支持 FTS5 全文索引
 	ctx    context.Context
 	cancel context.CancelFunc
 }
@@ -26,7 +50,9 @@ func NewConfigManager(dbPath string) (*ConfigManager, error) {
 		return nil, fmt.Errorf("创建数据库目录失败: %v", err)
 	}
 
-	db, err := sql.Open("sqlite3", dbPath)
+	// 后台发送协程和监控协程会同时写入，设置忙等待避免 database is locked 错误
+	// 事务开始时立即获取写锁，避免多个进程同时升级数据库时互相死锁
+	db, err := sql.Open("sqlite3", dbPath+"?_busy_timeout=5000&_txlock=immediate")
 	if err != nil {
 		return nil, fmt.Errorf("打开数据库失败: %v", err)
 	}
@@ -37,39 +63,35 @@ func NewConfigManager(dbPath string) (*ConfigManager, error) {
 		return nil, fmt.Errorf("连接数据库失败: %v", err)
 	}
 
-	// 创建配置表
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS config (
-			key TEXT PRIMARY KEY,
-			value TEXT
-		)
-	`)
-	if err != nil {
+	// 升级数据库结构到最新版本
+	if err := migrate(db); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("创建配置表失败: %v", err)
+		return nil, err
 	}
 
-	// 创建 MD5 表
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS uploaded_md5 (
-			md5 TEXT PRIMARY KEY,
-			upload_time INTEGER
-		)
-	`)
+	// 创建提示词全文索引
+	fts, err := initFTS(db)
 	if err != nil {
 		db.Close()
-		return nil, fmt.Errorf("创建 MD5 表失败: %v", err)
+		return nil, err
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &ConfigManager{
 		db:     db,
+		dir:    dbDir,
+		fts:    fts,
 		ctx:    ctx,
 		cancel: cancel,
 	}, nil
 }
 
+// Dir 返回配置数据库所在的目录，控制接口令牌等运行时文件也保存在该目录
+func (c *ConfigManager) Dir() string {
+	return c.dir
+}
+
 // SaveApiKey 保存 API Key
 func (c *ConfigManager) SaveApiKey(apiKey string) error {
 	_, err := c.db.Exec(`
@@ -104,6 +126,11 @@ func (c *ConfigManager) GetContext() context.Context {
 	return c.ctx
 }
 
+// Cancel 取消 context，通知所有依赖该 context 的监控任务退出，但不关闭数据库
+func (c *ConfigManager) Cancel() {
+	c.cancel()
+}
+
 // Close 关闭数据库连接
 func (c *ConfigManager) Close() error {
 	c.cancel() // 取消 context
@@ -112,31 +139,3 @@ func (c *ConfigManager) Close() error {
 	}
 	return nil
 }
-
-// 移动其他数据库相关方法...
-
-func (cm *ConfigManager) IsMD5Uploaded(md5 string) (bool, error) {
-	var exists bool
-	err := cm.db.QueryRow(`
-		SELECT EXISTS(
-			SELECT 1 FROM uploaded_md5 WHERE md5 = ?
-		)
-	`, md5).Scan(&exists)
-
-	if err != nil {
-		return false, fmt.Errorf("检查 MD5 失败: %v", err)
-	}
-	return exists, nil
-}
-
-func (cm *ConfigManager) SaveMD5(md5 string) error {
-	_, err := cm.db.Exec(`
-		INSERT OR REPLACE INTO uploaded_md5 (md5, upload_time)
-		VALUES (?, ?)
-	`, md5, time.Now().Unix())
-
-	if err != nil {
-		return fmt.Errorf("保存 MD5 失败: %v", err)
-	}
-	return nil
-}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PendingSinks 返回 sinks 中尚未发送过任一去重键的上传目标
+// 去重按上传目标记录，发送到某个目标不影响其他目标，新增的上传目标也会收到之前的记录
+func (c *ConfigManager) PendingSinks(sinks []string, keys ...string) ([]string, error) {
+	if len(sinks) == 0 || len(keys) == 0 {
+		return sinks, nil
+	}
+
+	condition, args := sinkCondition(sinks)
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	keyPlaceholders := strings.TrimSuffix(strings.Repeat("?,", len(keys)), ",")
+
+	rows, err := c.db.Query(`
+		SELECT DISTINCT sink FROM dedupe
+		WHERE `+condition+` AND key IN (`+keyPlaceholders+`)
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("检查去重键失败: %v", err)
+	}
+	defer rows.Close()
+
+	uploaded := make(map[string]bool)
+	for rows.Next() {
+		var sink string
+		if err := rows.Scan(&sink); err != nil {
+			return nil, fmt.Errorf("检查去重键失败: %v", err)
+		}
+		uploaded[sink] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("检查去重键失败: %v", err)
+	}
+
+	pending := make([]string, 0, len(sinks))
+	for _, sink := range sinks {
+		if !uploaded[sink] {
+			pending = append(pending, sink)
+		}
+	}
+	return pending, nil
+}
+
+// FirstSeenDay 返回 key 首次出现的日期（2006-01-02），第一次查询时记为 now 所在的日期
+func (c *ConfigManager) FirstSeenDay(key string, now time.Time) (string, error) {
+	if _, err := c.db.Exec(`
+		INSERT OR IGNORE INTO first_seen (key, day)
+		VALUES (?, ?)
+	`, key, now.Format("2006-01-02")); err != nil {
+		return "", fmt.Errorf("记录首次出现日期失败: %v", err)
+	}
+
+	var day string
+	if err := c.db.QueryRow(`SELECT day FROM first_seen WHERE key = ?`, key).Scan(&day); err != nil {
+		return "", fmt.Errorf("查询首次出现日期失败: %v", err)
+	}
+	return day, nil
+}
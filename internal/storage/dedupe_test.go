@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 工作区规则禁止上传时记录只发送到本地目标，本地目标发送成功后规则改为允许，仍应发送到服务器
+func TestPendingSinksAfterLocalDelivery(t *testing.T) {
+	c, err := NewConfigManager(filepath.Join(t.TempDir(), "config.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	const key = "sha256:test"
+	if _, err := c.EnqueueOutbox([]string{SinkFile}, "prompt", key, []byte(`{}`), "test"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := c.DueOutbox([]string{SinkFile}, time.Now(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("上传队列中有 %d 条记录，期望 1 条", len(entries))
+	}
+	if err := c.MarkOutboxDelivered(entries[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := c.PendingSinks([]string{SinkServer, SinkFile}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{SinkServer}; !reflect.DeepEqual(pending, want) {
+		t.Fatalf("待发送的上传目标为 %v，期望 %v", pending, want)
+	}
+}
+
+// 首次出现日期记录后不随读取日期变化
+func TestFirstSeenDayKeepsFirstDay(t *testing.T) {
+	c, err := NewConfigManager(filepath.Join(t.TempDir(), "config.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	first := time.Date(2024, 1, 31, 23, 0, 0, 0, time.Local)
+	for _, now := range []time.Time{first, first.AddDate(0, 0, 1), first.AddDate(0, 1, 0)} {
+		day, err := c.FirstSeenDay("sha256:test", now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if day != "2024-01-31" {
+			t.Fatalf("%s 读取到的首次出现日期为 %s，期望 2024-01-31", now.Format("2006-01-02"), day)
+		}
+	}
+}
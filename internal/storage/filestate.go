@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// FileKeyState state.vscdb 中某个键上次处理时的状态
+type FileKeyState struct {
+	ValueHash string // 键值的哈希，未变化时跳过处理
+	Count     int    // 解析出的条目数，数组只追加时只处理新增的条目
+	TailHash  string // 最后一个已处理条目的哈希，用于确认数组只是追加而不是被改写
+}
+
+// LoadFileState 加载 state.vscdb 中各键上次处理时的状态
+func (c *ConfigManager) LoadFileState(path string) (map[string]FileKeyState, error) {
+	rows, err := c.db.Query(`
+		SELECT key, value_hash, item_count, tail_hash FROM file_state
+		WHERE path = ?
+	`, path)
+	if err != nil {
+		return nil, fmt.Errorf("加载文件状态失败: %v", err)
+	}
+	defer rows.Close()
+
+	states := make(map[string]FileKeyState)
+	for rows.Next() {
+		var key string
+		var state FileKeyState
+		if err := rows.Scan(&key, &state.ValueHash, &state.Count, &state.TailHash); err != nil {
+			return nil, fmt.Errorf("加载文件状态失败: %v", err)
+		}
+		states[key] = state
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("加载文件状态失败: %v", err)
+	}
+	return states, nil
+}
+
+// SaveFileKeyState 保存 state.vscdb 中某个键的处理状态
+func (c *ConfigManager) SaveFileKeyState(path, key string, state FileKeyState) error {
+	_, err := c.db.Exec(`
+		INSERT OR REPLACE INTO file_state (path, key, value_hash, item_count, tail_hash, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, path, key, state.ValueHash, state.Count, state.TailHash, time.Now().Unix())
+	if err != nil {
+		return fmt.Errorf("保存文件状态失败: %v", err)
+	}
+	return nil
+}
+
+// ClearFileState 清除所有文件的处理状态，下次处理时重新提取全部内容
+func (c *ConfigManager) ClearFileState() error {
+	if _, err := c.db.Exec(`DELETE FROM file_state`); err != nil {
+		return fmt.Errorf("清除文件状态失败: %v", err)
+	}
+	return nil
+}
+
+// ClearPathFileState 清除单个文件的处理状态，下次处理时重新提取该文件的全部内容
+func (c *ConfigManager) ClearPathFileState(path string) error {
+	if _, err := c.db.Exec(`DELETE FROM file_state WHERE path = ?`, path); err != nil {
+		return fmt.Errorf("清除文件状态失败: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package storage
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationFiles 按版本号命名的升级脚本，如 0001_initial.sql，版本号必须从 1 开始连续递增
+// 已发布的脚本不能再修改，结构变更一律新增脚本
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migration 一个数据库结构版本的升级脚本
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// loadMigrations 读取内嵌的升级脚本并按版本号排序
+func loadMigrations() ([]migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, fmt.Errorf("读取升级脚本失败: %v", err)
+	}
+
+	var migrations []migration
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("升级脚本 %s 缺少版本号", entry.Name())
+		}
+
+		data, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("读取升级脚本 %s 失败: %v", entry.Name(), err)
+		}
+		migrations = append(migrations, migration{version: version, name: name, sql: string(data)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+	for i, m := range migrations {
+		if m.version != i+1 {
+			return nil, fmt.Errorf("升级脚本版本号不连续: %s", m.name)
+		}
+	}
+	return migrations, nil
+}
+
+// LatestSchemaVersion 返回当前程序支持的最新数据库结构版本
+func LatestSchemaVersion() int {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0
+	}
+	return len(migrations)
+}
+
+// SchemaVersion 返回数据库当前的结构版本
+func (c *ConfigManager) SchemaVersion() (int, error) {
+	return schemaVersion(c.db)
+}
+
+// migrate 把数据库升级到最新结构版本，每个版本在独立事务中执行，失败时回滚该版本
+// 数据库版本高于程序支持的版本时拒绝打开，避免旧程序写坏新结构
+func migrate(db *sql.DB) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	if err := initSchemaVersion(db); err != nil {
+		return err
+	}
+
+	current, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if current > len(migrations) {
+		return fmt.Errorf("数据库结构版本 %d 高于程序支持的版本 %d，请升级程序", current, len(migrations))
+	}
+
+	for _, m := range migrations[current:] {
+		if err := applyMigration(db, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// schemaVersion 查询数据库当前的结构版本，0 表示空数据库
+func schemaVersion(db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("查询数据库结构版本失败: %v", err)
+	}
+	return version, nil
+}
+
+// initSchemaVersion 创建版本表；引入版本表之前创建的数据库根据已有的表识别版本，并记录为已升级
+func initSchemaVersion(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("创建数据库版本表失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow(`SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'`).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("检查数据库版本表失败: %v", err)
+	}
+	if exists {
+		return nil
+	}
+
+	_, err = tx.Exec(`
+		CREATE TABLE schema_version (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at INTEGER NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("创建数据库版本表失败: %v", err)
+	}
+
+	legacy, err := detectLegacyVersion(tx)
+	if err != nil {
+		return err
+	}
+	if legacy > 0 {
+		migrations, err := loadMigrations()
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations[:legacy] {
+			if _, err := tx.Exec(`INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)`, m.version, m.name, time.Now().Unix()); err != nil {
+				return fmt.Errorf("记录数据库结构版本失败: %v", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("创建数据库版本表失败: %v", err)
+	}
+	return nil
+}
+
+// detectLegacyVersion 根据已有的表识别引入版本表之前的数据库结构版本
+// 引入版本表之前发布的版本只有配置表和 MD5 表，即版本 1，之后新增的升级脚本不需要修改这里
+func detectLegacyVersion(tx *sql.Tx) (int, error) {
+	var config bool
+	err := tx.QueryRow(`SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'config'`).Scan(&config)
+	if err != nil {
+		return 0, fmt.Errorf("识别数据库结构版本失败: %v", err)
+	}
+	if config {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// applyMigration 在事务中执行一个版本的升级脚本并记录版本
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("升级数据库到 %s 失败: %v", m.name, err)
+	}
+	defer tx.Rollback()
+
+	// 其他进程可能已经完成了这个版本的升级
+	var applied bool
+	if err := tx.QueryRow(`SELECT COUNT(*) > 0 FROM schema_version WHERE version = ?`, m.version).Scan(&applied); err != nil {
+		return fmt.Errorf("升级数据库到 %s 失败: %v", m.name, err)
+	}
+	if applied {
+		return nil
+	}
+
+	if _, err := tx.Exec(m.sql); err != nil {
+		return fmt.Errorf("升级数据库到 %s 失败: %v", m.name, err)
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)`, m.version, m.name, time.Now().Unix()); err != nil {
+		return fmt.Errorf("记录数据库结构版本失败: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("升级数据库到 %s 失败: %v", m.name, err)
+	}
+	return nil
+}
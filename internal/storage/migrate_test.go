@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// openRawDB 打开不经过升级的数据库，用于构造各版本的数据库
+func openRawDB(t *testing.T, path string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// tableExists 判断数据库中是否存在表
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+	var exists bool
+	err := db.QueryRow(`SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&exists)
+	if err != nil {
+		t.Fatalf("查询表 %s 失败: %v", name, err)
+	}
+	return exists
+}
+
+// checkLatest 检查数据库已升级到最新版本，且每个版本只记录一次
+func checkLatest(t *testing.T, c *ConfigManager) {
+	t.Helper()
+	version, err := c.SchemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest := LatestSchemaVersion(); version != latest || latest == 0 {
+		t.Fatalf("数据库结构版本为 %d，期望 %d", version, latest)
+	}
+
+	var rows int
+	if err := c.db.QueryRow(`SELECT COUNT(*) FROM schema_version`).Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != version {
+		t.Fatalf("版本表中有 %d 条记录，期望 %d 条", rows, version)
+	}
+
+	for _, table := range []string{"config", "prompts", "outbox", "file_state", "dedupe", "first_seen"} {
+		if !tableExists(t, c.db, table) {
+			t.Fatalf("升级后缺少表 %s", table)
+		}
+	}
+	if tableExists(t, c.db, "uploaded_md5") {
+		t.Fatal("升级后仍存在表 uploaded_md5")
+	}
+}
+
+func TestMigrateEmptyDatabase(t *testing.T) {
+	c, err := NewConfigManager(filepath.Join(t.TempDir(), "config.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	checkLatest(t, c)
+}
+
+func TestMigrateBaselineDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.db")
+
+	// 引入版本表之前发布的版本创建的数据库
+	db := openRawDB(t, path)
+	_, err := db.Exec(`
+		CREATE TABLE config (
+			key TEXT PRIMARY KEY,
+			value TEXT
+		);
+		CREATE TABLE uploaded_md5 (
+			md5 TEXT PRIMARY KEY,
+			upload_time INTEGER
+		);
+		INSERT INTO config (key, value) VALUES ('api_key', 'test-key');
+		INSERT INTO uploaded_md5 (md5, upload_time) VALUES ('0123456789abcdef0123456789abcdef', 1700000000);
+	`)
+	if err != nil {
+		t.Fatalf("创建旧版本数据库失败: %v", err)
+	}
+	db.Close()
+
+	c, err := NewConfigManager(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	checkLatest(t, c)
+
+	key, err := c.LoadApiKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "test-key" {
+		t.Fatalf("升级后 API Key 为 %q，期望 %q", key, "test-key")
+	}
+
+	// 旧版本只上传到提示词服务器
+	pending, err := c.PendingSinks([]string{SinkServer}, "md5:0123456789abcdef0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Fatal("升级后丢失了已上传的 MD5 记录")
+	}
+}
+
+func TestMigrateAlreadyMigrated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.db")
+
+	c, err := NewConfigManager(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SaveApiKey("test-key"); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	// 再次打开和再次执行升级都不应重复执行升级脚本
+	c, err = NewConfigManager(path)
+	if err != nil {
+		t.Fatalf("再次打开已升级的数据库失败: %v", err)
+	}
+	defer c.Close()
+	if err := migrate(c.db); err != nil {
+		t.Fatalf("再次升级失败: %v", err)
+	}
+
+	checkLatest(t, c)
+	key, err := c.LoadApiKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "test-key" {
+		t.Fatalf("再次升级后 API Key 为 %q，期望 %q", key, "test-key")
+	}
+}
+
+func TestMigrateRejectsNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.db")
+
+	c, err := NewConfigManager(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	// 模拟更新版本的程序升级过的数据库
+	db := openRawDB(t, path)
+	newer := LatestSchemaVersion() + 1
+	if _, err := db.Exec(`INSERT INTO schema_version (version, name, applied_at) VALUES (?, 'future', 0)`, newer); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	c, err = NewConfigManager(path)
+	if err == nil {
+		c.Close()
+		t.Fatal("打开结构版本更高的数据库应当失败")
+	}
+	if !strings.Contains(err.Error(), "高于程序支持的版本") {
+		t.Fatalf("错误信息不符合预期: %v", err)
+	}
+}
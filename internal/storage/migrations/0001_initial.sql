@@ -0,0 +1,10 @@
+-- 配置表和已上传 MD5 表（1.0.x 版本的初始结构）
+CREATE TABLE config (
+	key TEXT PRIMARY KEY,
+	value TEXT
+);
+
+CREATE TABLE uploaded_md5 (
+	md5 TEXT PRIMARY KEY,
+	upload_time INTEGER
+);
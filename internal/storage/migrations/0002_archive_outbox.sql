@@ -0,0 +1,35 @@
+-- 提示词本地归档表，全文索引 prompts_fts 依赖 FTS5 编译选项，在迁移之外按需创建
+-- 独立的归档库（sqlite 上传目标）也使用这张表，可能已经存在
+CREATE TABLE IF NOT EXISTS prompts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	hash TEXT NOT NULL UNIQUE,
+	text TEXT NOT NULL,
+	command_type INTEGER NOT NULL DEFAULT 0,
+	editor TEXT NOT NULL DEFAULT '',
+	workspace TEXT NOT NULL DEFAULT '',
+	git_remote TEXT NOT NULL DEFAULT '',
+	git_commit TEXT NOT NULL DEFAULT '',
+	git_branch TEXT NOT NULL DEFAULT '',
+	source_file TEXT NOT NULL DEFAULT '',
+	timestamp INTEGER NOT NULL DEFAULT 0,
+	created_at INTEGER NOT NULL
+);
+
+-- 持久化上传队列，每个上传目标一条记录
+CREATE TABLE outbox (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	sink TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	dedupe_key TEXT NOT NULL,
+	payload BLOB NOT NULL,
+	summary TEXT NOT NULL DEFAULT '',
+	status TEXT NOT NULL DEFAULT 'pending',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL,
+	UNIQUE (sink, dedupe_key)
+);
+
+CREATE INDEX idx_outbox_due ON outbox (status, sink, next_attempt);
@@ -0,0 +1,10 @@
+-- 每个 state.vscdb 中各键上次处理时的状态，用于增量提取
+CREATE TABLE file_state (
+	path TEXT NOT NULL,
+	key TEXT NOT NULL,
+	value_hash TEXT NOT NULL,
+	item_count INTEGER NOT NULL DEFAULT 0,
+	tail_hash TEXT NOT NULL DEFAULT '',
+	updated_at INTEGER NOT NULL,
+	PRIMARY KEY (path, key)
+);
@@ -0,0 +1,19 @@
+-- 去重表按上传目标记录已发送的去重键，替代只按提示词文本 MD5 去重的 uploaded_md5
+-- 原有记录都是上传到提示词服务器的，以 md5: 前缀记入 server 目标，全局去重且不区分日期时升级后不会重复上传
+CREATE TABLE dedupe (
+	sink TEXT NOT NULL,
+	key TEXT NOT NULL,
+	uploaded_at INTEGER NOT NULL,
+	PRIMARY KEY (sink, key)
+);
+
+INSERT OR IGNORE INTO dedupe (sink, key, uploaded_at)
+SELECT 'server', 'md5:' || md5, upload_time FROM uploaded_md5;
+
+DROP TABLE uploaded_md5;
+
+-- 提示词首次出现的日期，按日期去重时使用，state.vscdb 中的提示词本身没有时间
+CREATE TABLE first_seen (
+	key TEXT PRIMARY KEY,
+	day TEXT NOT NULL
+);
@@ -0,0 +1,255 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// outbox 记录状态
+const (
+	OutboxPending   = "pending"   // 等待发送或等待重试
+	OutboxFailed    = "failed"    // 不可重试的错误或超过最大重试次数，已放弃
+	OutboxDelivered = "delivered" // 已成功发送
+)
+
+// OutboxEntry 待发送到某个上传目标的记录
+type OutboxEntry struct {
+	ID          int64
+	Sink        string // 上传目标名称
+	Kind        string // 记录类型，如 prompt、conversation
+	DedupeKey   string // 去重键，发送成功后记入去重表
+	Payload     []byte
+	Summary     string // 用于日志显示的简短描述
+	Attempts    int
+	NextAttempt time.Time
+	LastError   string
+}
+
+// OutboxStatus outbox 中各状态的记录数
+type OutboxStatus struct {
+	Pending   int `json:"pending"`
+	Failed    int `json:"failed"`
+	Delivered int `json:"delivered"`
+}
+
+// EnqueueOutbox 把记录加入各上传目标的队列，返回新加入的条数（已在队列中的目标会被跳过）
+func (c *ConfigManager) EnqueueOutbox(sinks []string, kind string, dedupeKey string, payload []byte, summary string) (int, error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("加入上传队列失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	added := 0
+	for _, sink := range sinks {
+		result, err := tx.Exec(`
+			INSERT OR IGNORE INTO outbox (sink, kind, dedupe_key, payload, summary, status, next_attempt, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, sink, kind, dedupeKey, payload, summary, OutboxPending, now.UnixMilli(), now.Unix(), now.Unix())
+		if err != nil {
+			return 0, fmt.Errorf("加入上传队列失败: %v", err)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("加入上传队列失败: %v", err)
+		}
+		added += int(affected)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("加入上传队列失败: %v", err)
+	}
+	return added, nil
+}
+
+// sinkCondition 生成只包含指定上传目标的查询条件
+func sinkCondition(sinks []string) (string, []interface{}) {
+	if len(sinks) == 0 {
+		return "0", nil
+	}
+	args := make([]interface{}, 0, len(sinks))
+	for _, sink := range sinks {
+		args = append(args, sink)
+	}
+	return "sink IN (" + strings.TrimSuffix(strings.Repeat("?,", len(sinks)), ",") + ")", args
+}
+
+// DueOutbox 返回指定上传目标中到期需要发送的记录，按入队顺序排列
+func (c *ConfigManager) DueOutbox(sinks []string, now time.Time, limit int) ([]OutboxEntry, error) {
+	condition, args := sinkCondition(sinks)
+	args = append([]interface{}{OutboxPending, now.UnixMilli()}, append(args, limit)...)
+
+	rows, err := c.db.Query(`
+		SELECT id, sink, kind, dedupe_key, payload, summary, attempts, next_attempt, last_error
+		FROM outbox
+		WHERE status = ? AND next_attempt <= ? AND `+condition+`
+		ORDER BY id
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询上传队列失败: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []OutboxEntry
+	for rows.Next() {
+		var entry OutboxEntry
+		var nextAttempt int64
+		if err := rows.Scan(&entry.ID, &entry.Sink, &entry.Kind, &entry.DedupeKey, &entry.Payload, &entry.Summary,
+			&entry.Attempts, &nextAttempt, &entry.LastError); err != nil {
+			return nil, fmt.Errorf("读取上传队列失败: %v", err)
+		}
+		entry.NextAttempt = time.UnixMilli(nextAttempt)
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取上传队列失败: %v", err)
+	}
+	return entries, nil
+}
+
+// CountDueOutbox 返回指定上传目标中到期需要发送的记录数
+func (c *ConfigManager) CountDueOutbox(sinks []string, now time.Time) (int, error) {
+	condition, args := sinkCondition(sinks)
+	args = append([]interface{}{OutboxPending, now.UnixMilli()}, args...)
+
+	var count int
+	err := c.db.QueryRow(`
+		SELECT COUNT(*) FROM outbox WHERE status = ? AND next_attempt <= ? AND `+condition, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("查询上传队列失败: %v", err)
+	}
+	return count, nil
+}
+
+// NextOutboxAttempt 返回指定上传目标中最近一条待发送记录的计划发送时间，没有待发送记录时返回 false
+func (c *ConfigManager) NextOutboxAttempt(sinks []string) (time.Time, bool, error) {
+	condition, args := sinkCondition(sinks)
+	args = append([]interface{}{OutboxPending}, args...)
+
+	var next sql.NullInt64
+	err := c.db.QueryRow(`
+		SELECT MIN(next_attempt) FROM outbox WHERE status = ? AND `+condition, args...).Scan(&next)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("查询上传队列失败: %v", err)
+	}
+	if !next.Valid {
+		return time.Time{}, false, nil
+	}
+	return time.UnixMilli(next.Int64), true, nil
+}
+
+// MarkOutboxDelivered 标记记录已发送，同时把去重键记入该上传目标的去重表并清空请求内容
+func (c *ConfigManager) MarkOutboxDelivered(entry OutboxEntry) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("更新上传队列失败: %v", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().Unix()
+	if _, err := tx.Exec(`
+		UPDATE outbox SET status = ?, attempts = attempts + 1, payload = '', last_error = '', updated_at = ?
+		WHERE id = ?
+	`, OutboxDelivered, now, entry.ID); err != nil {
+		return fmt.Errorf("更新上传队列失败: %v", err)
+	}
+	if _, err := tx.Exec(`
+		INSERT OR REPLACE INTO dedupe (sink, key, uploaded_at) VALUES (?, ?, ?)
+	`, entry.Sink, entry.DedupeKey, now); err != nil {
+		return fmt.Errorf("保存去重键失败: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("更新上传队列失败: %v", err)
+	}
+	return nil
+}
+
+// MarkOutboxRetry 记录发送失败，并安排在 next 时重试
+func (c *ConfigManager) MarkOutboxRetry(entry OutboxEntry, next time.Time, lastError string) error {
+	_, err := c.db.Exec(`
+		UPDATE outbox SET attempts = attempts + 1, next_attempt = ?, last_error = ?, updated_at = ?
+		WHERE id = ?
+	`, next.UnixMilli(), lastError, time.Now().Unix(), entry.ID)
+	if err != nil {
+		return fmt.Errorf("更新上传队列失败: %v", err)
+	}
+	return nil
+}
+
+// MarkOutboxFailed 标记记录发送失败且不再重试
+func (c *ConfigManager) MarkOutboxFailed(entry OutboxEntry, lastError string) error {
+	_, err := c.db.Exec(`
+		UPDATE outbox SET status = ?, attempts = attempts + 1, last_error = ?, updated_at = ?
+		WHERE id = ?
+	`, OutboxFailed, lastError, time.Now().Unix(), entry.ID)
+	if err != nil {
+		return fmt.Errorf("更新上传队列失败: %v", err)
+	}
+	return nil
+}
+
+// RetryFailedOutbox 把所有失败的记录重新加入队列，返回重新加入的条数
+func (c *ConfigManager) RetryFailedOutbox() (int, error) {
+	now := time.Now()
+	result, err := c.db.Exec(`
+		UPDATE outbox SET status = ?, attempts = 0, next_attempt = ?, updated_at = ?
+		WHERE status = ?
+	`, OutboxPending, now.UnixMilli(), now.Unix(), OutboxFailed)
+	if err != nil {
+		return 0, fmt.Errorf("重试上传队列失败: %v", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("重试上传队列失败: %v", err)
+	}
+	return int(affected), nil
+}
+
+// LastOutboxDelivery 返回最近一次成功发送的时间，没有发送过时返回 false
+func (c *ConfigManager) LastOutboxDelivery() (time.Time, bool, error) {
+	var last sql.NullInt64
+	err := c.db.QueryRow(`
+		SELECT MAX(updated_at) FROM outbox
+		WHERE status = ?
+	`, OutboxDelivered).Scan(&last)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("查询上传队列失败: %v", err)
+	}
+	if !last.Valid {
+		return time.Time{}, false, nil
+	}
+	return time.Unix(last.Int64, 0), true, nil
+}
+
+// GetOutboxStatus 返回上传队列中各状态的记录数
+func (c *ConfigManager) GetOutboxStatus() (OutboxStatus, error) {
+	rows, err := c.db.Query(`SELECT status, COUNT(*) FROM outbox GROUP BY status`)
+	if err != nil {
+		return OutboxStatus{}, fmt.Errorf("查询上传队列状态失败: %v", err)
+	}
+	defer rows.Close()
+
+	var status OutboxStatus
+	for rows.Next() {
+		var name string
+		var count int
+		if err := rows.Scan(&name, &count); err != nil {
+			return OutboxStatus{}, fmt.Errorf("查询上传队列状态失败: %v", err)
+		}
+		switch name {
+		case OutboxPending:
+			status.Pending = count
+		case OutboxFailed:
+			status.Failed = count
+		case OutboxDelivered:
+			status.Delivered = count
+		}
+	}
+	return status, rows.Err()
+}
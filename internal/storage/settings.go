@@ -0,0 +1,432 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"cursor_history/internal/logging"
+)
+
+// 配置表中的配置项名称
+const (
+	SettingStorageDirs     = "storage_dirs"      // 显式指定的 workspaceStorage 目录
+	SettingBatchSize       = "batch_size"        // 每批上传的最大记录数
+	SettingBatchMaxLatency = "batch_max_latency" // 新记录等待凑批的最长时间
+	SettingSinks           = "sinks"             // 上传目标列表（JSON）
+	SettingRedactRules     = "redact_rules"      // 自定义脱敏规则（JSON）
+	SettingRedactDisable   = "redact_disable"    // 要关闭的内置脱敏检测器，逗号分隔
+	SettingWorkspaceRules  = "workspace_rules"   // 工作区上传规则（JSON）
+	SettingDebounceWindow  = "debounce_window"   // 文件停止写入多久后才处理
+	SettingDebounceMaxWait = "debounce_max_wait" // 文件持续写入时最长等待多久处理一次
+	SettingWatchMode       = "watch_mode"        // 文件监控方式
+	SettingPollInterval    = "poll_interval"     // 轮询监控的间隔
+	SettingStartupScan     = "startup_scan"      // 启动时是否补扫已有的工作区数据库
+	SettingScanSince       = "scan_since"        // 启动补扫只处理该时间之后修改过的数据库
+	SettingDedupeScope     = "dedupe_scope"      // 去重范围
+	SettingDedupeByDay     = "dedupe_by_day"     // 去重时是否区分日期
+	SettingGitDisable      = "git_disable"       // 不收集的 Git 信息，逗号分隔
+	SettingReadMode        = "read_mode"         // 读取 state.vscdb 的方式
+	SettingReadBusyTimeout = "read_busy_timeout" // 读取 state.vscdb 遇到锁时的忙等待时间
+	SettingReadRetries     = "read_retries"      // 读取 state.vscdb 被占用或不完整时的重试次数
+	SettingLogLevel        = "log_level"         // 日志级别，可按组件配置
+	SettingControlAddr     = "control_addr"      // 本机控制接口的监听地址
+	SettingMetricsAddr     = "metrics_addr"      // Prometheus 指标接口的监听地址
+	SettingLogFormat       = "log_format"        // 日志文件格式
+	SettingLogMaxSize      = "log_max_size"      // 单个日志文件的最大大小（MB）
+	SettingLogMaxAge       = "log_max_age"       // 日志文件按该时长轮转
+	SettingLogMaxBackups   = "log_max_backups"   // 保留的历史日志文件数
+	SettingLogCompress     = "log_compress"      // 是否压缩历史日志文件
+)
+
+// 上传目标类型
+const (
+	SinkServer  = "server"  // 提示词服务器
+	SinkFile    = "file"    // 本地 JSONL 文件
+	SinkWebhook = "webhook" // 通用 Webhook
+	SinkSQLite  = "sqlite"  // 本地 SQLite 归档
+)
+
+// SinkConfig 上传目标配置
+type SinkConfig struct {
+	Type     string            `json:"type"`
+	Name     string            `json:"name,omitempty"`     // 目标名称，默认与类型相同，同类型配置多个时必须指定
+	Path     string            `json:"path,omitempty"`     // file、sqlite：文件路径
+	URL      string            `json:"url,omitempty"`      // webhook：请求地址
+	Template string            `json:"template,omitempty"` // webhook：请求体模板（text/template）
+	Headers  map[string]string `json:"headers,omitempty"`  // webhook：附加请求头
+}
+
+// SinkName 返回上传目标名称
+func (s SinkConfig) SinkName() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Type
+}
+
+// ParseSinkConfigs 解析上传目标配置，空字符串表示只上传到提示词服务器
+func ParseSinkConfigs(value string) ([]SinkConfig, error) {
+	if value == "" {
+		return []SinkConfig{{Type: SinkServer}}, nil
+	}
+
+	var configs []SinkConfig
+	if err := json.Unmarshal([]byte(value), &configs); err != nil {
+		return nil, fmt.Errorf("解析上传目标配置失败: %v", err)
+	}
+
+	seen := make(map[string]bool, len(configs))
+	for _, config := range configs {
+		name := config.SinkName()
+		if seen[name] {
+			return nil, fmt.Errorf("上传目标名称重复: %s", name)
+		}
+		seen[name] = true
+	}
+	return configs, nil
+}
+
+// LoadSinkConfigs 加载上传目标配置
+func (c *ConfigManager) LoadSinkConfigs() ([]SinkConfig, error) {
+	value, err := c.LoadSetting(SettingSinks)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSinkConfigs(value)
+}
+
+// UploadSettings 上传参数
+type UploadSettings struct {
+	BatchSize       int
+	BatchMaxLatency time.Duration
+}
+
+// DefaultUploadSettings 默认上传参数
+func DefaultUploadSettings() UploadSettings {
+	return UploadSettings{
+		BatchSize:       50,
+		BatchMaxLatency: 2 * time.Second,
+	}
+}
+
+// 文件监控方式
+const (
+	WatchModeAuto     = "auto"     // 使用 fsnotify，不可用或频繁出错时改为轮询
+	WatchModeFSNotify = "fsnotify" // 只使用 fsnotify，出错后重启
+	WatchModePoll     = "poll"     // 定期扫描目录
+)
+
+// WatchSettings 文件监控参数
+type WatchSettings struct {
+	Mode            string
+	PollInterval    time.Duration
+	DebounceWindow  time.Duration
+	DebounceMaxWait time.Duration
+}
+
+// DefaultWatchSettings 默认文件监控参数
+func DefaultWatchSettings() WatchSettings {
+	return WatchSettings{
+		Mode:            WatchModeAuto,
+		PollInterval:    10 * time.Second,
+		DebounceWindow:  500 * time.Millisecond,
+		DebounceMaxWait: 5 * time.Second,
+	}
+}
+
+// ValidateWatchMode 校验文件监控方式
+func ValidateWatchMode(mode string) error {
+	switch mode {
+	case WatchModeAuto, WatchModeFSNotify, WatchModePoll:
+		return nil
+	}
+	return fmt.Errorf("无效的监控方式: %s，可用: %s、%s、%s", mode, WatchModeAuto, WatchModeFSNotify, WatchModePoll)
+}
+
+// SaveSetting 保存配置项
+func (c *ConfigManager) SaveSetting(key, value string) error {
+	_, err := c.db.Exec(`
+		INSERT OR REPLACE INTO config (key, value)
+		VALUES (?, ?)
+	`, key, value)
+	if err != nil {
+		return fmt.Errorf("保存配置 %s 失败: %v", key, err)
+	}
+	return nil
+}
+
+// LoadSetting 加载配置项，不存在时返回空字符串
+func (c *ConfigManager) LoadSetting(key string) (string, error) {
+	var value string
+	err := c.db.QueryRow(`
+		SELECT value FROM config
+		WHERE key = ?
+	`, key).Scan(&value)
+
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("加载配置 %s 失败: %v", key, err)
+	}
+	return value, nil
+}
+
+// SaveStorageDirs 保存显式指定的 workspaceStorage 目录，多个目录用系统路径分隔符分隔，空字符串表示自动查找
+func (c *ConfigManager) SaveStorageDirs(dirs string) error {
+	return c.SaveSetting(SettingStorageDirs, dirs)
+}
+
+// LoadStorageDirs 加载显式指定的 workspaceStorage 目录
+func (c *ConfigManager) LoadStorageDirs() (string, error) {
+	return c.LoadSetting(SettingStorageDirs)
+}
+
+// LoadUploadSettings 加载上传参数，未配置的项使用默认值
+func (c *ConfigManager) LoadUploadSettings() (UploadSettings, error) {
+	settings := DefaultUploadSettings()
+
+	if value, err := c.LoadSetting(SettingBatchSize); err != nil {
+		return settings, err
+	} else if value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 1 {
+			return settings, fmt.Errorf("无效的 %s: %s", SettingBatchSize, value)
+		}
+		settings.BatchSize = size
+	}
+
+	if value, err := c.LoadSetting(SettingBatchMaxLatency); err != nil {
+		return settings, err
+	} else if value != "" {
+		latency, err := time.ParseDuration(value)
+		if err != nil || latency < 0 {
+			return settings, fmt.Errorf("无效的 %s: %s", SettingBatchMaxLatency, value)
+		}
+		settings.BatchMaxLatency = latency
+	}
+
+	return settings, nil
+}
+
+// LoadWatchSettings 加载文件监控参数，未配置的项使用默认值；任一项无效时整体使用默认值
+func (c *ConfigManager) LoadWatchSettings() (WatchSettings, error) {
+	settings := DefaultWatchSettings()
+
+	if value, err := c.LoadSetting(SettingWatchMode); err != nil {
+		return DefaultWatchSettings(), err
+	} else if value != "" {
+		if err := ValidateWatchMode(value); err != nil {
+			return DefaultWatchSettings(), err
+		}
+		settings.Mode = value
+	}
+
+	for key, target := range map[string]*time.Duration{
+		SettingPollInterval:    &settings.PollInterval,
+		SettingDebounceWindow:  &settings.DebounceWindow,
+		SettingDebounceMaxWait: &settings.DebounceMaxWait,
+	} {
+		value, err := c.LoadSetting(key)
+		if err != nil {
+			return DefaultWatchSettings(), err
+		}
+		if value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 || (key == SettingPollInterval && d == 0) {
+			return DefaultWatchSettings(), fmt.Errorf("无效的 %s: %s", key, value)
+		}
+		*target = d
+	}
+
+	return settings, nil
+}
+
+// ScanSettings 启动补扫参数
+type ScanSettings struct {
+	OnStart bool
+	Since   time.Time // 零值表示不限制修改时间
+}
+
+// ParseSince 解析补扫的起始时间，支持日期（2006-01-02）、RFC3339 时间，以及相对 now 的时长（如 720h、30d）
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	} else if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("无效的时间: %s，可使用日期（如 2024-01-31）或时长（如 720h、30d）", value)
+}
+
+// LoadScanSettings 加载启动补扫参数，默认启动时补扫所有工作区数据库
+func (c *ConfigManager) LoadScanSettings() (ScanSettings, error) {
+	settings := ScanSettings{OnStart: true}
+
+	value, err := c.LoadSetting(SettingStartupScan)
+	if err != nil {
+		return settings, err
+	}
+	if value != "" {
+		onStart, err := strconv.ParseBool(value)
+		if err != nil {
+			return settings, fmt.Errorf("无效的 %s: %s", SettingStartupScan, value)
+		}
+		settings.OnStart = onStart
+	}
+
+	value, err = c.LoadSetting(SettingScanSince)
+	if err != nil {
+		return settings, err
+	}
+	if value != "" {
+		since, err := ParseSince(value, time.Now())
+		if err != nil {
+			return settings, err
+		}
+		settings.Since = since
+	}
+	return settings, nil
+}
+
+// 去重范围
+const (
+	DedupeScopeWorkspace = "workspace" // 同一工作区内相同的提示词只上传一次
+	DedupeScopeGlobal    = "global"    // 所有工作区中相同的提示词只上传一次
+)
+
+// DedupeSettings 去重参数
+type DedupeSettings struct {
+	Scope string
+	ByDay bool // 不同日期的相同提示词分别上传，日期为首次读到提示词的日期
+}
+
+// ValidateDedupeScope 校验去重范围
+func ValidateDedupeScope(scope string) error {
+	switch scope {
+	case DedupeScopeWorkspace, DedupeScopeGlobal:
+		return nil
+	}
+	return fmt.Errorf("无效的去重范围: %s，可用: %s、%s", scope, DedupeScopeWorkspace, DedupeScopeGlobal)
+}
+
+// LoadDedupeSettings 加载去重参数，默认按工作区去重且不区分日期
+func (c *ConfigManager) LoadDedupeSettings() (DedupeSettings, error) {
+	settings := DedupeSettings{Scope: DedupeScopeWorkspace}
+
+	value, err := c.LoadSetting(SettingDedupeScope)
+	if err != nil {
+		return settings, err
+	}
+	if value != "" {
+		if err := ValidateDedupeScope(value); err != nil {
+			return settings, err
+		}
+		settings.Scope = value
+	}
+
+	value, err = c.LoadSetting(SettingDedupeByDay)
+	if err != nil {
+		return settings, err
+	}
+	if value != "" {
+		byDay, err := strconv.ParseBool(value)
+		if err != nil {
+			return settings, fmt.Errorf("无效的 %s: %s", SettingDedupeByDay, value)
+		}
+		settings.ByDay = byDay
+	}
+	return settings, nil
+}
+
+// LoadLogLevels 加载各组件的日志级别，未配置时只记录 INFO 及以上的日志
+func (c *ConfigManager) LoadLogLevels() (logging.Levels, error) {
+	value, err := c.LoadSetting(SettingLogLevel)
+	if err != nil {
+		return logging.DefaultLevels(), err
+	}
+	levels, err := logging.ParseLevels(value)
+	if err != nil {
+		return logging.DefaultLevels(), fmt.Errorf("无效的 %s: %v", SettingLogLevel, err)
+	}
+	return levels, nil
+}
+
+// LoadLogOptions 加载写入 path 的日志文件参数，未配置的项使用默认值
+func (c *ConfigManager) LoadLogOptions(path string) (logging.FileOptions, error) {
+	opts := logging.DefaultFileOptions(path)
+
+	value, err := c.LoadSetting(SettingLogFormat)
+	if err != nil {
+		return opts, err
+	}
+	if value != "" {
+		if err := logging.ValidateFormat(value); err != nil {
+			return opts, err
+		}
+		opts.Format = value
+	}
+
+	value, err = c.LoadSetting(SettingLogMaxSize)
+	if err != nil {
+		return opts, err
+	}
+	if value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 0 {
+			return opts, fmt.Errorf("无效的 %s: %s", SettingLogMaxSize, value)
+		}
+		opts.MaxSize = int64(size) << 20
+	}
+
+	value, err = c.LoadSetting(SettingLogMaxAge)
+	if err != nil {
+		return opts, err
+	}
+	if value != "" {
+		age, err := time.ParseDuration(value)
+		if err != nil || age < 0 {
+			return opts, fmt.Errorf("无效的 %s: %s", SettingLogMaxAge, value)
+		}
+		opts.MaxAge = age
+	}
+
+	value, err = c.LoadSetting(SettingLogMaxBackups)
+	if err != nil {
+		return opts, err
+	}
+	if value != "" {
+		backups, err := strconv.Atoi(value)
+		if err != nil || backups < 0 {
+			return opts, fmt.Errorf("无效的 %s: %s", SettingLogMaxBackups, value)
+		}
+		opts.MaxBackups = backups
+	}
+
+	value, err = c.LoadSetting(SettingLogCompress)
+	if err != nil {
+		return opts, err
+	}
+	if value != "" {
+		compress, err := strconv.ParseBool(value)
+		if err != nil {
+			return opts, fmt.Errorf("无效的 %s: %s", SettingLogCompress, value)
+		}
+		opts.Compress = compress
+	}
+	return opts, nil
+}
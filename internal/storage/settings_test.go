@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// 任一监控参数无效时，已加载的有效参数也不应生效
+func TestLoadWatchSettingsInvalidUsesDefaults(t *testing.T) {
+	c, err := NewConfigManager(filepath.Join(t.TempDir(), "config.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.SaveSetting(SettingWatchMode, WatchModePoll); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SaveSetting(SettingDebounceWindow, "2s"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SaveSetting(SettingPollInterval, "0"); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, err := c.LoadWatchSettings()
+	if err == nil {
+		t.Fatal("轮询间隔为 0 时应返回错误")
+	}
+	if settings != DefaultWatchSettings() {
+		t.Fatalf("监控参数为 %+v，期望默认值 %+v", settings, DefaultWatchSettings())
+	}
+}
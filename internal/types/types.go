@@ -14,4 +14,5 @@ const (
 	LogLevelSuccess = "SUCCESS"
 	LogLevelInfo    = "INFO"
 	LogLevelDefault = "DEFAULT"
+	LogLevelDebug   = "DEBUG"
 )
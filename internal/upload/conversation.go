@@ -0,0 +1,146 @@
+package upload
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"cursor_history/internal/conversation"
+	"cursor_history/internal/source"
+	"cursor_history/internal/storage"
+	"cursor_history/internal/types"
+	"cursor_history/internal/vscdb"
+	"cursor_history/internal/workspace"
+)
+
+// ConversationUploadPath 完整对话的上传接口
+const ConversationUploadPath = "/api/conversation/upload"
+
+// globalStoragePath 返回工作区数据库对应的全局 state.vscdb 路径
+// 工作区数据库位于 User/workspaceStorage/<id>/state.vscdb，全局数据库位于 User/globalStorage/state.vscdb
+func globalStoragePath(workspaceDB string) string {
+	userDir := filepath.Dir(filepath.Dir(filepath.Dir(workspaceDB)))
+	return filepath.Join(userDir, "globalStorage", "state.vscdb")
+}
+
+// openGlobalLookup 打开全局数据库并返回 cursorDiskKV 查找函数，全局数据库不存在时返回 nil
+func openGlobalLookup(path string) (conversation.Lookup, func(), error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, func() {}, nil
+	}
+
+	db, err := vscdb.Open(path, currentReadOptions())
+	if err != nil {
+		return nil, func() {}, fmt.Errorf("无法打开全局数据库: %v", err)
+	}
+
+	lookup := func(key string) (string, bool) {
+		value, ok, err := db.Value("cursorDiskKV", key)
+		if err != nil {
+			return "", false
+		}
+		return value, ok
+	}
+	return lookup, func() { db.Close() }, nil
+}
+
+// processConversations 解析工作区数据库中的完整对话并上传
+func processConversations(values map[string]string, file FileInfo, src *source.Source, states map[string]storage.FileKeyState, configManager *storage.ConfigManager, ws workspace.Workspace, logger types.Logger) {
+	lookup, closeLookup, err := openGlobalLookup(globalStoragePath(file.Path))
+	if err != nil {
+		logger.Log(types.LogLevelError, "%v", err)
+	}
+	defer closeLookup()
+
+	var conversations []conversation.Conversation
+	hashes := make(map[string]string) // 本次解析的键及其键值哈希，全部上传成功后记录
+	for _, key := range src.ConversationKeys {
+		value, ok := values[key.Name]
+		if !ok {
+			continue
+		}
+
+		// 键值未变化时跳过；Composer 索引中包含各对话的更新时间，对话有新消息时索引也会变化
+		hash := valueHash(value)
+		if states[key.Name].ValueHash == hash {
+			continue
+		}
+
+		parsed, err := key.Parse(value, lookup)
+		if err != nil {
+			logger.Log(types.LogLevelError, "解析 %s 失败: %v", key.Name, err)
+			continue
+		}
+		conversations = append(conversations, parsed...)
+		hashes[key.Name] = hash
+	}
+
+	if len(hashes) == 0 {
+		return
+	}
+
+	// 获取 Git 信息
+	gitInfo := getGitInfo(ws.LocalPath(), logger)
+	allowed := workspaceAllowed(ws.Path(), gitInfo.RemoteURL, logger)
+
+	ok := true
+	for _, conv := range conversations {
+		conv = redactConversation(conv, logger)
+		if !uploadConversation(conv, src.Name, ws, gitInfo, allowed, configManager, logger) {
+			ok = false
+		}
+	}
+	if !ok {
+		return
+	}
+
+	for name, hash := range hashes {
+		if err := configManager.SaveFileKeyState(file.Path, name, storage.FileKeyState{ValueHash: hash}); err != nil {
+			logger.Log(types.LogLevelError, "%v", err)
+		}
+	}
+}
+
+// uploadConversation 上传完整对话，按去重键跳过已上传的对话，返回是否处理成功
+func uploadConversation(conv conversation.Conversation, editor string, ws workspace.Workspace, gitInfo GitInfo, allowed bool, configManager *storage.ConfigManager, logger types.Logger) bool {
+	sinks := uploadSinkNames(KindConversation, allowed)
+	if len(sinks) == 0 {
+		return true
+	}
+
+	content, err := json.Marshal(conv)
+	if err != nil {
+		logger.Log(types.LogLevelError, "JSON 编码失败: %v", err)
+		return false
+	}
+
+	// 对话有新消息时内容变化，去重键随之变化，从而重新上传
+	md5Value := textMD5(string(content))
+	settings := currentDedupeSettings()
+	key := conversationDedupeKey(content, ws, settings)
+	sinks, err = configManager.PendingSinks(sinks, dedupeKeys(key, md5Value, settings)...)
+	if err != nil {
+		logger.Log(types.LogLevelError, "%v", err)
+		return false
+	}
+	if len(sinks) == 0 {
+		duplicatesSkipped.Inc(KindConversation)
+		return true
+	}
+
+	data := map[string]interface{}{
+		"conversation":  conv,
+		"files":         conv.Files(),
+		"md5":           md5Value,
+		"dedupeKey":     key,
+		"workspace":     ws.Path(),
+		"workspaceInfo": ws,
+		"editor":        editor,
+		"uploadTime":    time.Now().UnixMilli(),
+		"git":           gitInfo,
+	}
+	summary := fmt.Sprintf("对话 %s (%s, %d 条消息)", conv.Title, conv.Kind, len(conv.Messages))
+	return enqueueUpload(sinks, KindConversation, key, data, summary, configManager, logger)
+}
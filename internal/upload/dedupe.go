@@ -0,0 +1,120 @@
+package upload
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cursor_history/internal/storage"
+	"cursor_history/internal/types"
+	"cursor_history/internal/workspace"
+)
+
+// 当前使用的去重参数
+var (
+	dedupeSettings      = storage.DedupeSettings{Scope: storage.DedupeScopeWorkspace}
+	dedupeSettingsMutex sync.Mutex
+)
+
+// loadDedupeSettings 根据配置加载去重参数，配置无效时使用默认值
+func loadDedupeSettings(configManager *storage.ConfigManager, logger types.Logger) storage.DedupeSettings {
+	settings, err := configManager.LoadDedupeSettings()
+	if err != nil {
+		logger.Log(types.LogLevelWarning, "%v，按工作区去重", err)
+	}
+	return settings
+}
+
+// setDedupeSettings 设置当前使用的去重参数
+func setDedupeSettings(settings storage.DedupeSettings) {
+	dedupeSettingsMutex.Lock()
+	defer dedupeSettingsMutex.Unlock()
+	dedupeSettings = settings
+}
+
+// currentDedupeSettings 返回当前使用的去重参数
+func currentDedupeSettings() storage.DedupeSettings {
+	dedupeSettingsMutex.Lock()
+	defer dedupeSettingsMutex.Unlock()
+	return dedupeSettings
+}
+
+// dedupeKey 计算去重键：对各字段以 \x00 分隔后取 SHA-256
+func dedupeKey(fields ...string) string {
+	hash := sha256.Sum256([]byte(strings.Join(fields, "\x00")))
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
+
+// legacyDedupeKey 返回旧版本按内容 MD5 去重时记录的去重键
+func legacyDedupeKey(md5Value string) string {
+	return "md5:" + md5Value
+}
+
+// dedupeKeys 返回检查是否已发送时使用的去重键
+// 旧版本的记录不包含工作区和日期，只在全局去重且不区分日期时视为已发送，
+// 否则升级前上传过的内容在其他工作区或其他日期也永远不会再上传
+func dedupeKeys(key, md5Value string, settings storage.DedupeSettings) []string {
+	if settings.Scope == storage.DedupeScopeGlobal && !settings.ByDay {
+		return []string{key, legacyDedupeKey(md5Value)}
+	}
+	return []string{key}
+}
+
+// textMD5 计算文本的 MD5，随上传数据一起发送，与旧版本保持兼容
+func textMD5(text string) string {
+	hash := md5.Sum([]byte(text))
+	return hex.EncodeToString(hash[:])
+}
+
+// promptDedupeKey 计算提示词的去重键，由文本、命令类型和来源键组成，
+// 按工作区去重时加入工作区，按日期去重时加入提示词首次出现的日期 day
+func promptDedupeKey(prompt UploadPrompt, meta promptMeta, settings storage.DedupeSettings, day string) string {
+	fields := promptDedupeFields(prompt, meta, settings)
+	if settings.ByDay {
+		fields = append(fields, day)
+	}
+	return dedupeKey(fields...)
+}
+
+// promptDedupeFields 返回不含日期的去重字段
+func promptDedupeFields(prompt UploadPrompt, meta promptMeta, settings storage.DedupeSettings) []string {
+	fields := []string{KindPrompt, prompt.Text, strconv.Itoa(prompt.CommandType), meta.SourceKey}
+	if settings.Scope == storage.DedupeScopeWorkspace {
+		fields = append(fields, meta.Workspace.Name())
+	}
+	return fields
+}
+
+// promptFirstSeenDay 返回提示词数组中相同提示词第 occurrence 次（从 0 开始）出现的首次读到日期
+// state.vscdb 中的提示词没有时间，文件修改时间也会随新的提示词变化，因此按出现次数记录首次读到的日期：
+// 之后重新读取时沿用记录的日期不再上传，在新的一天重复输入的提示词出现次数增加，按当天日期上传
+func promptFirstSeenDay(prompt UploadPrompt, occurrence int, meta promptMeta, settings storage.DedupeSettings, configManager *storage.ConfigManager) (string, error) {
+	fields := append(promptDedupeFields(prompt, meta, settings), strconv.Itoa(occurrence))
+	return configManager.FirstSeenDay(dedupeKey(fields...), time.Now())
+}
+
+// promptOccurrences 返回每条提示词之前出现过几次相同的提示词（文本和命令类型都相同）
+func promptOccurrences(prompts []UploadPrompt) []int {
+	seen := make(map[string]int, len(prompts))
+	occurrences := make([]int, len(prompts))
+	for i, prompt := range prompts {
+		hash := promptHash(prompt)
+		occurrences[i] = seen[hash]
+		seen[hash]++
+	}
+	return occurrences
+}
+
+// conversationDedupeKey 计算对话的去重键，对话内容变化时去重键也会变化，从而重新上传
+// 对话会持续更新，不按日期区分
+func conversationDedupeKey(content []byte, ws workspace.Workspace, settings storage.DedupeSettings) string {
+	fields := []string{KindConversation, string(content)}
+	if settings.Scope == storage.DedupeScopeWorkspace {
+		fields = append(fields, ws.Name())
+	}
+	return dedupeKey(fields...)
+}
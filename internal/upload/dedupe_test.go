@@ -0,0 +1,29 @@
+package upload
+
+import (
+	"testing"
+
+	"cursor_history/internal/storage"
+)
+
+// 旧版本按 MD5 记录的去重键只在全局去重且不区分日期时使用
+func TestDedupeKeysLegacyOnlyForGlobalScope(t *testing.T) {
+	tests := []struct {
+		settings storage.DedupeSettings
+		legacy   bool
+	}{
+		{storage.DedupeSettings{Scope: storage.DedupeScopeGlobal}, true},
+		{storage.DedupeSettings{Scope: storage.DedupeScopeGlobal, ByDay: true}, false},
+		{storage.DedupeSettings{Scope: storage.DedupeScopeWorkspace}, false},
+		{storage.DedupeSettings{Scope: storage.DedupeScopeWorkspace, ByDay: true}, false},
+	}
+	for _, tt := range tests {
+		keys := dedupeKeys("sha256:key", "md5value", tt.settings)
+		if keys[0] != "sha256:key" {
+			t.Fatalf("%+v: 第一个去重键为 %s", tt.settings, keys[0])
+		}
+		if got := len(keys) == 2 && keys[1] == legacyDedupeKey("md5value"); got != tt.legacy {
+			t.Fatalf("%+v: 去重键为 %v，是否包含旧版本去重键期望为 %v", tt.settings, keys, tt.legacy)
+		}
+	}
+}
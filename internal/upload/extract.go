@@ -0,0 +1,100 @@
+package upload
+
+import (
+	"sort"
+
+	"cursor_history/internal/redact"
+	"cursor_history/internal/source"
+	"cursor_history/internal/storage"
+	"cursor_history/internal/types"
+	"cursor_history/internal/vscdb"
+)
+
+// ReadPrompts 直接读取各目录下 state.vscdb 中满足过滤条件的提示词，不归档也不上传，用于导出
+// 提示词按配置脱敏，时间为数据库的修改时间；没有 workspace.json 的空窗口和读取失败的数据库会跳过
+func ReadPrompts(roots []source.Root, filter storage.SearchFilter, configManager *storage.ConfigManager, logger types.Logger) []storage.ArchivedPrompt {
+	opts := loadReadOptions(configManager, logger)
+	redactor := openRedactor(configManager, logger)
+
+	stamps := scanStateFiles(roots)
+	paths := make([]string, 0, len(stamps))
+	for path, stamp := range stamps {
+		// 提示词的时间就是数据库的修改时间，较早的数据库不会有满足条件的提示词
+		if !filter.Since.IsZero() && stamp.latest().Before(filter.Since) {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var (
+		prompts []storage.ArchivedPrompt
+		hits    []redact.Hit
+	)
+	for _, path := range paths {
+		ws, ok := loadWorkspace(path, logger)
+		if !ok {
+			continue
+		}
+
+		src := sourceForFile(roots, path)
+		keys := make([]string, 0, len(src.Keys))
+		for _, key := range src.Keys {
+			keys = append(keys, key.Name)
+		}
+		values, err := vscdb.ReadValues(path, "ItemTable", keys, opts)
+		if err != nil {
+			logReadError(err, logger)
+			continue
+		}
+
+		var (
+			git       GitInfo
+			gitLoaded bool
+		)
+		for _, key := range src.Keys {
+			value, ok := values[key.Name]
+			if !ok {
+				continue
+			}
+			list, err := convertValueToUploadPrompt(value, key, src.Name)
+			if err != nil {
+				logger.Log(types.LogLevelWarning, "%s: %v", path, err)
+				continue
+			}
+
+			for _, prompt := range list {
+				p := storage.ArchivedPrompt{
+					Text:        prompt.Text,
+					CommandType: prompt.CommandType,
+					Editor:      prompt.Editor,
+					Workspace:   ws.Path(),
+					SourceFile:  path,
+					Timestamp:   stamps[path].latest().Unix(),
+				}
+				if !filter.Match(p) {
+					continue
+				}
+
+				text, textHits := redactor.Redact(p.Text)
+				p.Text = text
+				hits = append(hits, textHits...)
+
+				// 只在有需要导出的提示词时获取 Git 信息
+				if !gitLoaded {
+					git = getGitInfo(ws.LocalPath(), logger)
+					gitLoaded = true
+				}
+				p.GitRemote = git.RemoteURL
+				p.GitCommit = git.CommitHash
+				p.GitBranch = git.BranchName
+				prompts = append(prompts, p)
+			}
+		}
+	}
+
+	if len(hits) > 0 {
+		logger.Log(types.LogLevelWarning, "已脱敏导出的提示词中的敏感信息: %s", redact.FormatHits(mergeHits(hits)))
+	}
+	return prompts
+}
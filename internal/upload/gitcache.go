@@ -0,0 +1,389 @@
+package upload
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"cursor_history/internal/types"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-git/go-git/v5"
+)
+
+// Git 信息缓存的刷新间隔
+const (
+	gitStatusTTL  = 10 * time.Second // 工作区状态的缓存时间，修改文件不会触发 .git 目录的变化
+	gitCacheTTL   = 30 * time.Second // 无法监控 .git 目录时仓库信息的缓存时间
+	gitMissingTTL = time.Minute      // 不在 Git 仓库中的工作区多久后重新查找
+)
+
+// 当前使用的 Git 信息缓存，监控启动时创建，监控结束时关闭
+var (
+	activeGitCache *gitCache
+	gitCacheMutex  sync.Mutex
+)
+
+// setGitCache 设置当前使用的 Git 信息缓存
+func setGitCache(cache *gitCache) {
+	gitCacheMutex.Lock()
+	defer gitCacheMutex.Unlock()
+	activeGitCache = cache
+}
+
+// currentGitCache 返回当前使用的 Git 信息缓存，未设置时返回 nil
+func currentGitCache() *gitCache {
+	gitCacheMutex.Lock()
+	defer gitCacheMutex.Unlock()
+	return activeGitCache
+}
+
+// gitCache 按工作区缓存 Git 仓库和仓库信息
+// 每个仓库只打开一次，同一仓库中的多个工作区共用；监控仓库的 HEAD、引用和配置，变化后才重新读取仓库信息
+// 加入监控的目录由 addLoop 在锁外逐个加入：Windows 上 watcher.Add 要等待事件协程，
+// 持有锁或在 run 中调用会与等待锁的 run 互相等待
+type gitCache struct {
+	mu         sync.Mutex
+	watcher    *fsnotify.Watcher // 为 nil 时按 gitCacheTTL 定期刷新
+	workspaces map[string]*gitWorkspace
+	repos      map[string]*gitRepoEntry // 按仓库根目录索引
+	pending    []gitWatchRequest        // 等待加入监控的目录
+	wake       chan struct{}            // 有新的监控请求时通知 addLoop
+	stop       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// gitWatchRequest 加入监控的请求，entry 不为 nil 时监控仓库的 .git 目录，否则递归监控新建的 refs 子目录 dir
+type gitWatchRequest struct {
+	entry *gitRepoEntry
+	dir   string
+}
+
+// gitWorkspace 工作区对应的仓库，repo 为 nil 表示不在 Git 仓库中
+type gitWorkspace struct {
+	repo      *gitRepoEntry
+	checkedAt time.Time
+}
+
+// gitRepoEntry 已打开的仓库及其缓存的信息
+// 缓存的字段由 gitCache.mu 保护；读取仓库信息和工作区状态时不持有 gitCache.mu，
+// 只持有该仓库的 infoMu、statusMu，同一仓库的其他调用等待读取结果，其他仓库不受影响
+type gitRepoEntry struct {
+	repo    *git.Repository
+	root    string
+	gitDirs []string // 监控的 .git 目录，为空表示未监控
+
+	infoMu sync.Mutex
+	info   GitInfo
+	valid  bool
+	infoAt time.Time
+
+	statusMu    sync.Mutex
+	dirty       *bool
+	modified    []string
+	statusValid bool
+	statusAt    time.Time
+}
+
+// newGitCache 创建 Git 信息缓存，无法创建文件监控时按固定间隔刷新
+func newGitCache(logger types.Logger) *gitCache {
+	logger = componentLogger(logger, componentGit)
+	c := &gitCache{
+		workspaces: make(map[string]*gitWorkspace),
+		repos:      make(map[string]*gitRepoEntry),
+		wake:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Log(types.LogLevelWarning, "无法监控 Git 仓库变化: %v，Git 信息每 %v 刷新一次", err, gitCacheTTL)
+		return c
+	}
+	c.watcher = watcher
+	c.wg.Add(2)
+	go c.run(logger)
+	go c.addLoop(logger)
+	return c
+}
+
+// Close 停止监控 Git 仓库
+func (c *gitCache) Close() {
+	if c.watcher != nil {
+		close(c.stop)
+		c.watcher.Close()
+	}
+	c.wg.Wait()
+}
+
+// Get 返回工作区的 Git 信息
+func (c *gitCache) Get(workspace string, fields map[string]bool, logger types.Logger) GitInfo {
+	entry := c.lookup(workspace, logger)
+	if entry == nil {
+		return GitInfo{}
+	}
+
+	info := c.info(entry, fields, logger)
+	if fields[GitFieldDirty] {
+		info.Dirty, info.ModifiedFiles = c.status(entry, logger)
+	}
+	if fields[GitFieldRelativePath] {
+		info.RelativePath = gitRelativePath(entry.root, workspace, logger)
+	}
+	return info
+}
+
+// lookup 返回工作区所在的仓库，不在 Git 仓库中时返回 nil
+func (c *gitCache) lookup(workspace string, logger types.Logger) *gitRepoEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	ws := c.workspaces[workspace]
+	if ws == nil || (ws.repo == nil && now.Sub(ws.checkedAt) > gitMissingTTL) {
+		ws = &gitWorkspace{repo: c.open(workspace, logger), checkedAt: now}
+		c.workspaces[workspace] = ws
+	}
+	return ws.repo
+}
+
+// info 返回仓库信息，缓存失效时在锁外重新读取
+func (c *gitCache) info(entry *gitRepoEntry, fields map[string]bool, logger types.Logger) GitInfo {
+	entry.infoMu.Lock()
+	defer entry.infoMu.Unlock()
+
+	c.mu.Lock()
+	now := time.Now()
+	stale := !entry.valid || (len(entry.gitDirs) == 0 && now.Sub(entry.infoAt) > gitCacheTTL)
+	info := entry.info
+	// 先标记为有效，读取期间发生的变化会再次使其失效，下次调用时重新读取
+	entry.valid = true
+	c.mu.Unlock()
+	if !stale {
+		return info
+	}
+
+	info = readGitInfo(entry.repo, fields, logger)
+	c.mu.Lock()
+	entry.info = info
+	entry.infoAt = now
+	c.mu.Unlock()
+	return info
+}
+
+// status 返回工作区状态，超过 gitStatusTTL 时在锁外重新读取；大仓库的状态检查需要扫描整个工作区
+func (c *gitCache) status(entry *gitRepoEntry, logger types.Logger) (*bool, []string) {
+	entry.statusMu.Lock()
+	defer entry.statusMu.Unlock()
+
+	c.mu.Lock()
+	now := time.Now()
+	stale := !entry.statusValid || now.Sub(entry.statusAt) > gitStatusTTL
+	dirty, modified := entry.dirty, entry.modified
+	entry.statusValid = true
+	c.mu.Unlock()
+	if !stale {
+		return dirty, modified
+	}
+
+	dirty, modified = readGitStatus(entry.repo, logger)
+	c.mu.Lock()
+	entry.dirty, entry.modified = dirty, modified
+	entry.statusAt = now
+	c.mu.Unlock()
+	return dirty, modified
+}
+
+// open 查找工作区所在的仓库，同一仓库只打开一次，调用时必须持有锁
+func (c *gitCache) open(workspace string, logger types.Logger) *gitRepoEntry {
+	repo, err := openGitRepo(workspace)
+	if err != nil {
+		return nil
+	}
+
+	root := gitWorktreeRoot(repo)
+	if entry := c.repos[root]; entry != nil && root != "" {
+		return entry
+	}
+
+	entry := &gitRepoEntry{repo: repo, root: root}
+	if root != "" {
+		c.repos[root] = entry
+		c.queueWatch(gitWatchRequest{entry: entry})
+	}
+	return entry
+}
+
+// queueWatch 把目录加入监控队列，调用时必须持有锁；加入监控之前仓库按 gitCacheTTL 定期刷新
+func (c *gitCache) queueWatch(req gitWatchRequest) {
+	if c.watcher == nil {
+		return
+	}
+	c.pending = append(c.pending, req)
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// addLoop 在锁外把队列中的目录加入监控
+func (c *gitCache) addLoop(logger types.Logger) {
+	defer c.wg.Done()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-c.wake:
+		}
+
+		c.mu.Lock()
+		pending := c.pending
+		c.pending = nil
+		c.mu.Unlock()
+
+		for _, req := range pending {
+			if req.entry == nil {
+				c.watchTree(req.dir)
+				continue
+			}
+			c.watch(req.entry, logger)
+		}
+	}
+}
+
+// watch 监控仓库的 .git 目录和 refs 目录，失败时该仓库按 gitCacheTTL 定期刷新，调用时不能持有锁
+func (c *gitCache) watch(entry *gitRepoEntry, logger types.Logger) {
+	gitDirs := gitDirsOf(entry.root)
+	if len(gitDirs) == 0 {
+		return
+	}
+	for _, dir := range gitDirs {
+		if err := c.watcher.Add(dir); err != nil {
+			logger.Log(types.LogLevelWarning, "监控 Git 仓库失败: %v，%s 的 Git 信息每 %v 刷新一次", err, entry.root, gitCacheTTL)
+			return
+		}
+		if err := c.watchTree(filepath.Join(dir, "refs")); err != nil {
+			logger.Log(types.LogLevelWarning, "监控 Git 仓库失败: %v，%s 的 Git 信息每 %v 刷新一次", err, entry.root, gitCacheTTL)
+			return
+		}
+	}
+
+	// 加入监控之前的变化没有事件，重新读取一次仓库信息
+	c.mu.Lock()
+	entry.gitDirs = gitDirs
+	entry.valid = false
+	c.mu.Unlock()
+}
+
+// watchTree 递归监控目录，目录不存在时忽略，调用时不能持有锁
+func (c *gitCache) watchTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		return c.watcher.Add(path)
+	})
+}
+
+// run 处理 .git 目录的变化，HEAD、引用或配置变化时使对应仓库的缓存失效
+func (c *gitCache) run(logger types.Logger) {
+	defer c.wg.Done()
+	for {
+		select {
+		case event, ok := <-c.watcher.Events:
+			if !ok {
+				return
+			}
+			c.handleEvent(event)
+
+		case err, ok := <-c.watcher.Errors:
+			if !ok {
+				return
+			}
+			// 可能丢失了事件，所有仓库都重新读取
+			logger.Log(types.LogLevelWarning, "监控 Git 仓库出错: %v", err)
+			c.mu.Lock()
+			for _, entry := range c.repos {
+				entry.valid = false
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+// handleEvent 找到事件所在的仓库并使其缓存失效，新建的 refs 子目录加入监控队列
+func (c *gitCache) handleEvent(event fsnotify.Event) {
+	if strings.HasSuffix(event.Name, ".lock") {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, entry := range c.repos {
+		for _, dir := range entry.gitDirs {
+			rel, err := filepath.Rel(dir, event.Name)
+			if err != nil || strings.HasPrefix(rel, "..") {
+				continue
+			}
+			rel = filepath.ToSlash(rel)
+			if rel != "HEAD" && rel != "config" && rel != "packed-refs" && !strings.HasPrefix(rel, "refs/") {
+				continue
+			}
+
+			if event.Op&fsnotify.Create != 0 && strings.HasPrefix(rel, "refs/") {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					c.queueWatch(gitWatchRequest{dir: event.Name})
+				}
+			}
+			entry.valid = false
+			if rel == "HEAD" {
+				entry.statusValid = false // 切换分支后工作区状态也会变化
+			}
+		}
+	}
+}
+
+// gitDirsOf 返回工作区根目录对应的 .git 目录，链接工作区（git worktree）还包括共用的主仓库目录
+func gitDirsOf(root string) []string {
+	dotGit := filepath.Join(root, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return nil
+	}
+	if info.IsDir() {
+		return []string{dotGit}
+	}
+
+	// .git 文件的内容为 "gitdir: <路径>"
+	data, err := os.ReadFile(dotGit)
+	if err != nil {
+		return nil
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return nil
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(root, gitDir)
+	}
+	dirs := []string{filepath.Clean(gitDir)}
+
+	if data, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		common := strings.TrimSpace(string(data))
+		if !filepath.IsAbs(common) {
+			common = filepath.Join(gitDir, common)
+		}
+		dirs = append(dirs, filepath.Clean(common))
+	}
+	return dirs
+}
@@ -0,0 +1,159 @@
+package upload
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// testLogger 把日志输出到测试日志
+type testLogger struct {
+	t *testing.T
+}
+
+func (l testLogger) Log(level string, format string, args ...interface{}) {
+	l.t.Logf("[%s] %s", level, fmt.Sprintf(format, args...))
+}
+
+func (l testLogger) Close() error {
+	return nil
+}
+
+// initTestRepo 创建包含一次提交的仓库，返回仓库目录和提交哈希
+func initTestRepo(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("创建仓库失败: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("test\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worktree.Add("README"); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := worktree.Commit("init", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatalf("提交失败: %v", err)
+	}
+	return dir, hash.String()
+}
+
+// 仓库持续产生事件（新建 refs 子目录、切换分支）时并发读取 Git 信息不应卡住，切换分支后能读到新的分支
+func TestGitCacheGetWhileEventsArrive(t *testing.T) {
+	dir, hash := initTestRepo(t)
+	gitDir := filepath.Join(dir, ".git")
+	if err := os.WriteFile(filepath.Join(gitDir, "refs", "heads", "other"), []byte(hash+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := testLogger{t}
+	cache := newGitCache(logger)
+	defer cache.Close()
+
+	stop := make(chan struct{})
+	writerDone := make(chan struct{})
+	go func() {
+		defer close(writerDone)
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			// 新建的 refs 子目录需要加入监控
+			refDir := filepath.Join(gitDir, "refs", "heads", "topic", fmt.Sprint(i))
+			os.MkdirAll(refDir, 0755)
+			os.WriteFile(filepath.Join(refDir, "x"), []byte(hash+"\n"), 0644)
+			branch := "master"
+			if i%2 == 1 {
+				branch = "other"
+			}
+			os.WriteFile(filepath.Join(gitDir, "HEAD"), []byte("ref: refs/heads/"+branch+"\n"), 0644)
+		}
+	}()
+
+	getDone := make(chan struct{})
+	go func() {
+		defer close(getDone)
+		for i := 0; i < 200; i++ {
+			cache.Get(dir, nil, logger)
+		}
+	}()
+
+	select {
+	case <-getDone:
+	case <-time.After(20 * time.Second):
+		t.Fatal("有事件到达时读取 Git 信息超时，可能发生了死锁")
+	}
+	close(stop)
+	<-writerDone
+
+	// 事件处理完后切换分支，缓存应失效并读到新的分支
+	if err := os.WriteFile(filepath.Join(gitDir, "HEAD"), []byte("ref: refs/heads/other\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if info := cache.Get(dir, nil, logger); info.BranchName == "other" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("切换分支后仍读到旧的分支: %s", cache.Get(dir, nil, logger).BranchName)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// 一个仓库正在检查工作区状态时，读取其他仓库的 Git 信息不应等待
+func TestGitCacheStatusDoesNotBlockOtherRepos(t *testing.T) {
+	slowDir, _ := initTestRepo(t)
+	fastDir, _ := initTestRepo(t)
+
+	logger := testLogger{t}
+	cache := newGitCache(logger)
+	defer cache.Close()
+
+	fields := map[string]bool{GitFieldDirty: true}
+	cache.Get(slowDir, fields, logger)
+	slow := cache.lookup(slowDir, logger)
+
+	// 持有 statusMu 模拟正在扫描大仓库的工作区
+	slow.statusMu.Lock()
+	cache.mu.Lock()
+	slow.statusValid = false
+	cache.mu.Unlock()
+	slowDone := make(chan struct{})
+	go func() {
+		defer close(slowDone)
+		cache.Get(slowDir, fields, logger)
+	}()
+
+	fastDone := make(chan GitInfo)
+	go func() {
+		fastDone <- cache.Get(fastDir, fields, logger)
+	}()
+	select {
+	case info := <-fastDone:
+		if info.Dirty == nil || *info.Dirty {
+			t.Fatalf("工作区状态为 %v，期望干净", info.Dirty)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("读取其他仓库的 Git 信息被正在检查的工作区状态阻塞")
+	}
+
+	slow.statusMu.Unlock()
+	<-slowDone
+}
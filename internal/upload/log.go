@@ -0,0 +1,39 @@
+package upload
+
+import (
+	"cursor_history/internal/logging"
+	"cursor_history/internal/storage"
+	"cursor_history/internal/types"
+)
+
+// 日志组件，可通过 log_level 配置单独设置级别，如 info,watcher=debug
+const (
+	componentWatcher = "watcher" // 文件监控和轮询
+	componentProcess = "process" // 读取 state.vscdb 并提取提示词和对话
+	componentSender  = "sender"  // 上传队列的发送和重试
+	componentGit     = "git"     // Git 信息和缓存
+	componentScan    = "scan"    // 补扫已有的工作区数据库
+)
+
+// LogComponents 返回可单独配置日志级别的组件
+func LogComponents() []string {
+	return []string{componentWatcher, componentProcess, componentSender, componentGit, componentScan}
+}
+
+// withLogLevels 按配置的日志级别包装 logger，logger 已是 *logging.Logger 时保留其级别（如命令行指定的级别）
+func withLogLevels(configManager *storage.ConfigManager, logger types.Logger) *logging.Logger {
+	if l, ok := logger.(*logging.Logger); ok {
+		return l
+	}
+
+	levels, err := configManager.LoadLogLevels()
+	if err != nil {
+		logger.Log(types.LogLevelWarning, "%v，使用默认日志级别", err)
+	}
+	return logging.New(logger, levels)
+}
+
+// componentLogger 返回属于组件的日志记录器，logger 未按级别包装时使用默认级别
+func componentLogger(logger types.Logger, component string) *logging.Logger {
+	return logging.From(logger).Component(component)
+}
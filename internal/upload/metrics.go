@@ -0,0 +1,38 @@
+package upload
+
+import (
+	"errors"
+	"strconv"
+
+	"cursor_history/internal/metrics"
+)
+
+// 上传相关的 Prometheus 指标
+var (
+	filesProcessed = metrics.NewCounter("cursor_history_files_processed_total",
+		"读取并处理的 state.vscdb 次数", "editor")
+	promptsExtracted = metrics.NewCounter("cursor_history_prompts_extracted_total",
+		"从 state.vscdb 中提取的新提示词数", "editor")
+	duplicatesSkipped = metrics.NewCounter("cursor_history_duplicates_skipped_total",
+		"因已上传而跳过的记录数", "kind")
+	uploadsTotal = metrics.NewCounter("cursor_history_uploads_total",
+		"发送到上传目标的记录数，status 为 success、HTTP 状态码或 error", "sink", "status")
+	uploadDuration = metrics.NewHistogram("cursor_history_upload_duration_seconds",
+		"每批记录发送到上传目标的耗时", metrics.DefaultBuckets, "sink")
+	watcherErrors = metrics.NewCounter("cursor_history_watcher_errors_total",
+		"文件监控出错次数，包括添加目录监控失败")
+	watchedDirs = metrics.NewGauge("cursor_history_watched_directories",
+		"当前监控的目录数，轮询时为轮询的 workspaceStorage 目录数")
+)
+
+// uploadStatus 返回发送结果的 status 标签值
+func uploadStatus(err error) string {
+	if err == nil {
+		return "success"
+	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return strconv.Itoa(statusErr.StatusCode)
+	}
+	return "error"
+}
@@ -0,0 +1,70 @@
+package upload
+
+import (
+	"sync"
+
+	"cursor_history/internal/policy"
+	"cursor_history/internal/storage"
+	"cursor_history/internal/types"
+)
+
+// 当前使用的工作区上传规则，未设置时允许所有工作区
+var (
+	activePolicy *policy.Policy
+	policyMutex  sync.Mutex
+
+	// 已记录过拒绝日志的工作区，避免每次文件变化都重复记录
+	deniedLogged sync.Map
+)
+
+// openPolicy 根据配置加载工作区上传规则，配置无效时拒绝所有工作区上传，避免误传不允许分享的项目
+func openPolicy(configManager *storage.ConfigManager, logger types.Logger) *policy.Policy {
+	value, err := configManager.LoadSetting(storage.SettingWorkspaceRules)
+	if err != nil {
+		logger.Log(types.LogLevelError, "%v，所有工作区只保存到本地", err)
+		return policy.DenyAll()
+	}
+
+	p, err := policy.Load(value)
+	if err != nil {
+		logger.Log(types.LogLevelError, "%v，所有工作区只保存到本地", err)
+		return policy.DenyAll()
+	}
+	if rules := p.Config().Rules; len(rules) > 0 {
+		logger.Log(types.LogLevelInfo, "已加载 %d 条工作区上传规则", len(rules))
+	}
+	return p
+}
+
+// setActivePolicy 设置当前使用的工作区上传规则
+func setActivePolicy(p *policy.Policy) {
+	policyMutex.Lock()
+	defer policyMutex.Unlock()
+	activePolicy = p
+	deniedLogged.Range(func(key, _ interface{}) bool {
+		deniedLogged.Delete(key)
+		return true
+	})
+}
+
+// workspaceAllowed 判断工作区是否允许上传，拒绝时每个工作区只记录一次日志
+func workspaceAllowed(workspace, remote string, logger types.Logger) bool {
+	policyMutex.Lock()
+	p := activePolicy
+	policyMutex.Unlock()
+	if p == nil {
+		return true
+	}
+
+	decision := p.Evaluate(workspace, remote)
+	if !decision.Allowed {
+		if _, logged := deniedLogged.LoadOrStore(workspace, true); !logged {
+			if decision.Rule > 0 {
+				logger.Log(types.LogLevelInfo, "工作区 %s 被第 %d 条规则拒绝上传，只保存到本地", workspace, decision.Rule)
+			} else {
+				logger.Log(types.LogLevelInfo, "工作区 %s 未匹配任何规则，默认拒绝上传，只保存到本地", workspace)
+			}
+		}
+	}
+	return decision.Allowed
+}
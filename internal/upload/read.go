@@ -0,0 +1,87 @@
+package upload
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"cursor_history/internal/storage"
+	"cursor_history/internal/types"
+	"cursor_history/internal/vscdb"
+)
+
+// 当前读取 state.vscdb 的参数
+var (
+	readOptions      = vscdb.DefaultOptions()
+	readOptionsMutex sync.Mutex
+)
+
+// loadReadOptions 根据配置加载读取 state.vscdb 的参数，无效的配置项使用默认值
+func loadReadOptions(configManager *storage.ConfigManager, logger types.Logger) vscdb.Options {
+	opts := vscdb.DefaultOptions()
+
+	if value, err := configManager.LoadSetting(storage.SettingReadMode); err != nil {
+		logger.Log(types.LogLevelError, "%v", err)
+	} else if value != "" {
+		if err := vscdb.ValidateMode(value); err != nil {
+			logger.Log(types.LogLevelWarning, "%v，使用默认读取方式 %s", err, opts.Mode)
+		} else {
+			opts.Mode = value
+		}
+	}
+
+	if value, err := configManager.LoadSetting(storage.SettingReadBusyTimeout); err != nil {
+		logger.Log(types.LogLevelError, "%v", err)
+	} else if value != "" {
+		if d, err := time.ParseDuration(value); err != nil || d < 0 {
+			logger.Log(types.LogLevelWarning, "无效的 %s: %s，使用默认值 %v", storage.SettingReadBusyTimeout, value, opts.BusyTimeout)
+		} else {
+			opts.BusyTimeout = d
+		}
+	}
+
+	if value, err := configManager.LoadSetting(storage.SettingReadRetries); err != nil {
+		logger.Log(types.LogLevelError, "%v", err)
+	} else if value != "" {
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			logger.Log(types.LogLevelWarning, "无效的 %s: %s，使用默认值 %d", storage.SettingReadRetries, value, opts.Retries)
+		} else {
+			opts.Retries = n
+		}
+	}
+
+	return opts
+}
+
+// setReadOptions 设置读取 state.vscdb 的参数
+func setReadOptions(opts vscdb.Options) {
+	readOptionsMutex.Lock()
+	defer readOptionsMutex.Unlock()
+	readOptions = opts
+}
+
+// currentReadOptions 返回当前读取 state.vscdb 的参数
+func currentReadOptions() vscdb.Options {
+	readOptionsMutex.Lock()
+	defer readOptionsMutex.Unlock()
+	return readOptions
+}
+
+// logReadError 按失败类型记录读取 state.vscdb 的错误，被占用时等下次文件变化再处理
+func logReadError(err error, logger types.Logger) {
+	var readErr *vscdb.Error
+	if !errors.As(err, &readErr) {
+		logger.Log(types.LogLevelError, "读取数据库失败: %v", err)
+		return
+	}
+
+	switch readErr.Kind {
+	case vscdb.KindMissing:
+		logger.Log(types.LogLevelWarning, "%v", readErr)
+	case vscdb.KindLocked:
+		logger.Log(types.LogLevelWarning, "%v，将在文件下次变化时重试", readErr)
+	default:
+		logger.Log(types.LogLevelError, "%v", readErr)
+	}
+}
@@ -0,0 +1,127 @@
+package upload
+
+import (
+	"sync"
+
+	"cursor_history/internal/conversation"
+	"cursor_history/internal/redact"
+	"cursor_history/internal/storage"
+	"cursor_history/internal/types"
+)
+
+// 当前使用的脱敏器，未设置时使用只包含内置检测器的默认脱敏器
+var (
+	activeRedactor *redact.Redactor
+	redactorMutex  sync.Mutex
+)
+
+// openRedactor 根据配置创建脱敏器，配置无效时退回默认脱敏器，保证不会因配置错误而上传原文
+func openRedactor(configManager *storage.ConfigManager, logger types.Logger) *redact.Redactor {
+	redactor, rules, err := loadRedactor(configManager)
+	if err != nil {
+		logger.Log(types.LogLevelError, "%v，只使用内置脱敏规则", err)
+		return redact.Default()
+	}
+	if rules > 0 {
+		logger.Log(types.LogLevelInfo, "已加载 %d 条自定义脱敏规则", rules)
+	}
+	return redactor
+}
+
+// loadRedactor 读取自定义规则和关闭的内置检测器并创建脱敏器，返回自定义规则数
+func loadRedactor(configManager *storage.ConfigManager) (*redact.Redactor, int, error) {
+	rulesValue, err := configManager.LoadSetting(storage.SettingRedactRules)
+	if err != nil {
+		return nil, 0, err
+	}
+	disableValue, err := configManager.LoadSetting(storage.SettingRedactDisable)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rules, err := redact.ParseRules(rulesValue)
+	if err != nil {
+		return nil, 0, err
+	}
+	disabled, err := redact.ParseDisabled(disableValue)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	redactor, err := redact.New(rules, disabled)
+	if err != nil {
+		return nil, 0, err
+	}
+	return redactor, len(rules), nil
+}
+
+// setActiveRedactor 设置当前使用的脱敏器
+func setActiveRedactor(redactor *redact.Redactor) {
+	redactorMutex.Lock()
+	defer redactorMutex.Unlock()
+	activeRedactor = redactor
+}
+
+// currentRedactor 返回当前使用的脱敏器
+func currentRedactor() *redact.Redactor {
+	redactorMutex.Lock()
+	defer redactorMutex.Unlock()
+	if activeRedactor == nil {
+		activeRedactor = redact.Default()
+	}
+	return activeRedactor
+}
+
+// RedactionCounts 返回启动以来各脱敏检测器的累计命中次数
+func RedactionCounts() map[string]int {
+	return currentRedactor().Counts()
+}
+
+// redactPrompt 脱敏提示词文本，命中时记录检测器和次数，不记录敏感内容
+func redactPrompt(prompt UploadPrompt, logger types.Logger) UploadPrompt {
+	text, hits := currentRedactor().Redact(prompt.Text)
+	if len(hits) > 0 {
+		logger.Log(types.LogLevelWarning, "已脱敏提示词中的敏感信息: %s", redact.FormatHits(hits))
+		prompt.Text = text
+	}
+	return prompt
+}
+
+// redactConversation 脱敏对话标题和每条消息的文本
+func redactConversation(conv conversation.Conversation, logger types.Logger) conversation.Conversation {
+	redactor := currentRedactor()
+	var hits []redact.Hit
+
+	title, titleHits := redactor.Redact(conv.Title)
+	conv.Title = title
+	hits = append(hits, titleHits...)
+
+	messages := make([]conversation.Message, len(conv.Messages))
+	for i, msg := range conv.Messages {
+		text, msgHits := redactor.Redact(msg.Text)
+		msg.Text = text
+		messages[i] = msg
+		hits = append(hits, msgHits...)
+	}
+	conv.Messages = messages
+
+	if len(hits) > 0 {
+		logger.Log(types.LogLevelWarning, "已脱敏对话 %s 中的敏感信息: %s", conv.ID, redact.FormatHits(mergeHits(hits)))
+	}
+	return conv
+}
+
+// mergeHits 合并同一检测器的命中次数
+func mergeHits(hits []redact.Hit) []redact.Hit {
+	index := make(map[string]int)
+	var merged []redact.Hit
+	for _, hit := range hits {
+		if i, ok := index[hit.Detector]; ok {
+			merged[i].Count += hit.Count
+			continue
+		}
+		index[hit.Detector] = len(merged)
+		merged = append(merged, hit)
+	}
+	return merged
+}
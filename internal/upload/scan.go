@@ -0,0 +1,156 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"cursor_history/internal/source"
+	"cursor_history/internal/storage"
+	"cursor_history/internal/types"
+)
+
+// ScanOptions 补扫参数
+type ScanOptions struct {
+	Since    time.Time            // 只处理该时间之后修改过的数据库，零值表示全部处理
+	Full     bool                 // 忽略上次处理的状态，重新提取全部提示词（已上传的仍会被去重）
+	Progress func(p ScanProgress) // 每处理完一个数据库调用一次
+}
+
+// ScanProgress 补扫进度
+type ScanProgress struct {
+	Done  int
+	Total int
+	Path  string
+}
+
+// ScanResult 补扫结果
+type ScanResult struct {
+	Found    int // 找到的数据库数量
+	Scanned  int // 已处理的数据库数量
+	Skipped  int // 早于起始时间而跳过的数据库数量
+	Enqueued int // 新加入上传队列的记录数
+}
+
+// String 返回补扫结果的摘要
+func (r ScanResult) String() string {
+	return fmt.Sprintf("找到 %d 个数据库，处理 %d 个，跳过 %d 个较早的数据库，新加入上传队列 %d 条", r.Found, r.Scanned, r.Skipped, r.Enqueued)
+}
+
+// scanWorkspaces 依次处理各目录下已有的 state.vscdb，ctx 取消时在当前数据库处理完后停止
+// 已处理过且未变化的键会按文件状态跳过，已上传的提示词会按去重键跳过
+func scanWorkspaces(ctx context.Context, roots []source.Root, opts ScanOptions, configManager *storage.ConfigManager, logger types.Logger) ScanResult {
+	stamps := scanStateFiles(roots)
+	result := ScanResult{Found: len(stamps)}
+
+	paths := make([]string, 0, len(stamps))
+	for path, stamp := range stamps {
+		if !opts.Since.IsZero() && stamp.latest().Before(opts.Since) {
+			result.Skipped++
+			continue
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	before := outboxTotal(configManager, logger)
+	for i, path := range paths {
+		if ctx.Err() != nil {
+			break
+		}
+		if opts.Full {
+			if err := configManager.ClearPathFileState(path); err != nil {
+				logger.Log(types.LogLevelError, "%v", err)
+			}
+		}
+
+		processFile(FileInfo{Path: path, ModTime: stamps[path].latest().Unix()}, sourceForFile(roots, path), configManager, logger)
+		result.Scanned++
+		if opts.Progress != nil {
+			opts.Progress(ScanProgress{Done: i + 1, Total: len(paths), Path: path})
+		}
+	}
+	result.Enqueued = outboxTotal(configManager, logger) - before
+	return result
+}
+
+// outboxTotal 返回上传队列中的记录总数，用于统计补扫新加入的记录
+func outboxTotal(configManager *storage.ConfigManager, logger types.Logger) int {
+	status, err := configManager.GetOutboxStatus()
+	if err != nil {
+		logger.Log(types.LogLevelError, "%v", err)
+		return 0
+	}
+	return status.Pending + status.Failed + status.Delivered
+}
+
+// startupScan 监控启动时补扫已有的工作区数据库，进度每完成约 10% 记录一次
+func startupScan(ctx context.Context, roots []source.Root, since time.Time, configManager *storage.ConfigManager, logger types.Logger) {
+	logger = componentLogger(logger, componentScan)
+	if since.IsZero() {
+		logger.Log(types.LogLevelInfo, "开始补扫已有的工作区数据库")
+	} else {
+		logger.Log(types.LogLevelInfo, "开始补扫 %s 之后修改过的工作区数据库", since.Format("2006-01-02 15:04:05"))
+	}
+
+	result := scanWorkspaces(ctx, roots, ScanOptions{
+		Since: since,
+		Progress: func(p ScanProgress) {
+			if step := p.Total / 10; p.Done < p.Total && (step == 0 || p.Done%step == 0) {
+				logger.Log(types.LogLevelInfo, "补扫进度: %d/%d", p.Done, p.Total)
+			}
+		},
+	}, configManager, logger)
+
+	if ctx.Err() != nil {
+		logger.Log(types.LogLevelWarning, "补扫已中断: %s", result)
+		return
+	}
+	logger.Log(types.LogLevelInfo, "补扫完成: %s", result)
+}
+
+// Scan 补扫各目录下已有的工作区数据库并上传其中的提示词，完成后等待到期的上传记录发送完毕
+// 用于命令行按需补扫，ctx 取消时停止补扫和等待
+func Scan(ctx context.Context, roots []source.Root, opts ScanOptions, configManager *storage.ConfigManager, logger types.Logger) (ScanResult, error) {
+	if len(roots) == 0 {
+		return ScanResult{}, fmt.Errorf("未找到 workspaceStorage 目录")
+	}
+	logger = withLogLevels(configManager, logger)
+
+	stopPipeline := startPipeline(configManager, logger)
+	defer stopPipeline()
+
+	result := scanWorkspaces(ctx, roots, opts, configManager, componentLogger(logger, componentScan))
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+	waitOutboxDrained(ctx, configManager, logger)
+	return result, nil
+}
+
+// waitOutboxDrained 等待当前上传目标中到期的记录发送完毕，等待重试的记录留在队列中由下次运行发送
+func waitOutboxDrained(ctx context.Context, configManager *storage.ConfigManager, logger types.Logger) {
+	sinks := uploadSinkNames("", true)
+	if len(sinks) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		count, err := configManager.CountDueOutbox(sinks, time.Now())
+		if err != nil {
+			logger.Log(types.LogLevelError, "%v", err)
+			return
+		}
+		if count == 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package upload
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cursor_history/internal/logging"
+	"cursor_history/internal/storage"
+	"cursor_history/internal/types"
+)
+
+// 重试参数
+const (
+	minFetchSize     = 20               // 每次从队列中取出的最少记录数
+	maxSendAttempts  = 12               // 超过该次数后放弃，标记为 failed
+	retryBaseDelay   = 2 * time.Second  // 第一次重试的基础延迟
+	retryMaxDelay    = 30 * time.Minute // 重试延迟上限
+	senderIdleWakeup = 5 * time.Minute  // 队列为空时的最长等待时间
+)
+
+// senderWake 有新记录入队时唤醒发送协程
+var senderWake = make(chan struct{}, 1)
+
+// notifySender 唤醒发送协程，不阻塞
+func notifySender() {
+	select {
+	case senderWake <- struct{}{}:
+	default:
+	}
+}
+
+// httpStatusError 服务器返回了非 200 状态
+type httpStatusError struct {
+	StatusCode int
+	RetryAfter time.Duration // 服务器通过 Retry-After 指定的等待时间，未指定时为 0
+}
+
+func (e *httpStatusError) Error() string {
+	return "服务器返回错误状态: " + strconv.Itoa(e.StatusCode)
+}
+
+// newHTTPStatusError 根据响应创建错误，并解析 Retry-After 头
+func newHTTPStatusError(resp *http.Response) *httpStatusError {
+	return &httpStatusError{
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// retryable 判断状态码是否值得重试
+func (e *httpStatusError) retryable() bool {
+	return e.StatusCode == http.StatusRequestTimeout ||
+		e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode >= 500
+}
+
+// parseRetryAfter 解析 Retry-After 头，支持秒数和 HTTP 日期两种格式
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// backoffDelay 计算第 attempt 次失败后的重试延迟，指数增长并加入随机抖动，避免多个客户端同时重试
+func backoffDelay(attempt int) time.Duration {
+	delay := retryMaxDelay
+	if attempt < 20 {
+		if d := retryBaseDelay << uint(attempt); d < retryMaxDelay {
+			delay = d
+		}
+	}
+	// 在 [delay/2, delay] 之间随机
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// resetTimer 安全地重置定时器
+func resetTimer(timer *time.Timer, d time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(d)
+}
+
+// runSender 后台把上传队列中的记录发送到各上传目标，直到 done 关闭或 context 取消
+// 新记录入队后最多等待 BatchMaxLatency 凑成一批，凑满 BatchSize 条时立即发送
+func runSender(done <-chan struct{}, sinks []Sink, configManager *storage.ConfigManager, logger types.Logger) {
+	logger = componentLogger(logger, componentSender)
+	settings, err := configManager.LoadUploadSettings()
+	if err != nil {
+		logger.Log(types.LogLevelWarning, "%v，使用默认上传参数", err)
+	}
+
+	sinkNames := make([]string, 0, len(sinks))
+	sinkByName := make(map[string]Sink, len(sinks))
+	for _, sink := range sinks {
+		sinkNames = append(sinkNames, sink.Name())
+		sinkByName[sink.Name()] = sink
+	}
+
+	ctx := configManager.GetContext()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	var flushAt time.Time // 当前批次的最晚发送时间
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-senderWake:
+			if flushAt.IsZero() {
+				flushAt = time.Now().Add(settings.BatchMaxLatency)
+			}
+			count, err := configManager.CountDueOutbox(sinkNames, time.Now())
+			if err == nil && count < settings.BatchSize && time.Now().Before(flushAt) {
+				resetTimer(timer, time.Until(flushAt))
+				continue
+			}
+		case <-timer.C:
+		}
+		flushAt = time.Time{}
+
+		fetchSize := settings.BatchSize
+		if fetchSize < minFetchSize {
+			fetchSize = minFetchSize
+		}
+		fetched := sendDueEntries(done, sinkNames, sinkByName, fetchSize, settings, configManager, logger)
+
+		// 队列中还有到期记录时立即继续，否则等到最近一条记录的重试时间
+		wait := senderIdleWakeup
+		if fetched == fetchSize {
+			wait = 0
+		} else if next, ok, err := configManager.NextOutboxAttempt(sinkNames); err != nil {
+			logger.Log(types.LogLevelError, "%v", err)
+		} else if ok {
+			if d := time.Until(next); d < wait {
+				wait = d
+			}
+		}
+		resetTimer(timer, wait)
+	}
+}
+
+// sendDueEntries 取出一批到期记录，按上传目标分组，每组按 BatchSize 分批发送，返回本次取出的记录数
+func sendDueEntries(done <-chan struct{}, sinkNames []string, sinkByName map[string]Sink, fetchSize int, settings storage.UploadSettings, configManager *storage.ConfigManager, logger types.Logger) int {
+	entries, err := configManager.DueOutbox(sinkNames, time.Now(), fetchSize)
+	if err != nil {
+		logger.Log(types.LogLevelError, "%v", err)
+		return 0
+	}
+
+	groups := make(map[string][]storage.OutboxEntry)
+	for _, entry := range entries {
+		groups[entry.Sink] = append(groups[entry.Sink], entry)
+	}
+
+	for _, name := range sinkNames {
+		group := groups[name]
+		for start := 0; start < len(group); start += settings.BatchSize {
+			select {
+			case <-done:
+				return 0
+			default:
+			}
+			end := start + settings.BatchSize
+			if end > len(group) {
+				end = len(group)
+			}
+			deliverBatch(sinkByName[name], group[start:end], configManager, logger)
+		}
+	}
+	return len(entries)
+}
+
+// deliverBatch 把一批记录发送到上传目标，并逐条处理发送结果
+func deliverBatch(sink Sink, batch []storage.OutboxEntry, configManager *storage.ConfigManager, logger types.Logger) {
+	records := make([]Record, len(batch))
+	for i, entry := range batch {
+		records[i] = Record{Kind: entry.Kind, Key: entry.DedupeKey, Payload: entry.Payload}
+	}
+
+	start := time.Now()
+	errs := sink.Send(records)
+	uploadDuration.Observe(time.Since(start).Seconds(), sink.Name())
+
+	delivered := 0
+	for i, entry := range batch {
+		uploadsTotal.Inc(sink.Name(), uploadStatus(errs[i]))
+		if handleResult(entry, errs[i], configManager, logger) {
+			delivered++
+		}
+	}
+
+	switch {
+	case delivered == 1 && len(batch) == 1:
+		logger.Log(types.LogLevelSuccess, "成功上传到 %s: %s", sink.Name(), batch[0].Summary)
+	case delivered > 0:
+		logger.Log(types.LogLevelSuccess, "成功上传 %d 条记录到 %s", delivered, sink.Name())
+	}
+}
+
+// handleResult 根据发送结果标记已发送、安排重试或放弃，返回是否发送成功
+func handleResult(entry storage.OutboxEntry, err error, configManager *storage.ConfigManager, logger types.Logger) bool {
+	if err == nil {
+		if err := configManager.MarkOutboxDelivered(entry); err != nil {
+			logger.Log(types.LogLevelError, "%v", err)
+			return false
+		}
+		return true
+	}
+
+	// 上传目标不支持的记录已在发现时记录过警告，直接标记为失败
+	if errors.Is(err, errKindUnsupported) {
+		logging.From(logger).Debug("上传目标不支持该类记录，已放弃", "sink", entry.Sink, "kind", entry.Kind, "summary", entry.Summary)
+		if err := configManager.MarkOutboxFailed(entry, err.Error()); err != nil {
+			logger.Log(types.LogLevelError, "%v", err)
+		}
+		return false
+	}
+
+	var statusErr *httpStatusError
+	permanent := errors.As(err, &statusErr) && !statusErr.retryable()
+	if permanent || entry.Attempts+1 >= maxSendAttempts {
+		logger.Log(types.LogLevelError, "上传到 %s 失败，已放弃: %s: %v", entry.Sink, entry.Summary, err)
+		if err := configManager.MarkOutboxFailed(entry, err.Error()); err != nil {
+			logger.Log(types.LogLevelError, "%v", err)
+		}
+		return false
+	}
+
+	delay := backoffDelay(entry.Attempts)
+	if statusErr != nil && statusErr.RetryAfter > 0 {
+		delay = statusErr.RetryAfter
+	}
+	logger.Log(types.LogLevelWarning, "上传到 %s 失败，%v 后重试: %s: %v", entry.Sink, delay.Round(time.Second), entry.Summary, err)
+	if err := configManager.MarkOutboxRetry(entry, time.Now().Add(delay), err.Error()); err != nil {
+		logger.Log(types.LogLevelError, "%v", err)
+	}
+	return false
+}
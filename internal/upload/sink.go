@@ -0,0 +1,166 @@
+package upload
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"cursor_history/internal/app"
+	"cursor_history/internal/storage"
+	"cursor_history/internal/types"
+)
+
+// 记录类型
+const (
+	KindPrompt       = "prompt"
+	KindConversation = "conversation"
+)
+
+// Record 发送给上传目标的一条记录，Payload 为上传到提示词服务器的 JSON 数据
+type Record struct {
+	Kind    string
+	Key     string // 去重键
+	Payload json.RawMessage
+}
+
+// Sink 上传目标
+type Sink interface {
+	// Name 返回上传目标名称，用于区分上传队列中的记录
+	Name() string
+	// Local 返回记录是否只保存在本机，被工作区规则拒绝上传的记录只发送到本地目标
+	Local() bool
+	// Send 发送一批记录，返回与 records 一一对应的错误，nil 表示该记录发送成功
+	Send(records []Record) []error
+	// Close 释放上传目标占用的资源
+	Close() error
+}
+
+// kindFilter 可选接口，上传目标运行中发现不接受某类记录时（如服务器没有对话接口）返回 false，
+// 之后这类记录不再加入该目标的上传队列
+type kindFilter interface {
+	Accepts(kind string) bool
+}
+
+// errKindUnsupported 上传目标不接受该类记录，队列中已有的这类记录标记为失败，不再重试
+var errKindUnsupported = errors.New("上传目标不支持该类记录")
+
+// NewSink 根据配置创建上传目标
+func NewSink(config storage.SinkConfig, logger types.Logger) (Sink, error) {
+	switch config.Type {
+	case storage.SinkServer:
+		return newServerSink(config.SinkName(), componentLogger(logger, componentSender)), nil
+	case storage.SinkFile:
+		return newFileSink(config.SinkName(), config.Path)
+	case storage.SinkWebhook:
+		return newWebhookSink(config.SinkName(), config.URL, config.Template, config.Headers)
+	case storage.SinkSQLite:
+		return newSQLiteSink(config.SinkName(), config.Path)
+	default:
+		return nil, fmt.Errorf("未知的上传目标类型: %s", config.Type)
+	}
+}
+
+// ValidateSinkConfigs 检查上传目标配置是否有效，不会创建文件或发送请求
+func ValidateSinkConfigs(value string) error {
+	configs, err := storage.ParseSinkConfigs(value)
+	if err != nil {
+		return err
+	}
+
+	for _, config := range configs {
+		switch config.Type {
+		case storage.SinkServer:
+		case storage.SinkFile, storage.SinkSQLite:
+			if config.Path == "" {
+				return fmt.Errorf("上传目标 %s 未指定 path", config.SinkName())
+			}
+		case storage.SinkWebhook:
+			if config.URL == "" {
+				return fmt.Errorf("上传目标 %s 未指定 url", config.SinkName())
+			}
+			if _, err := parseWebhookTemplate(config.Template); err != nil {
+				return fmt.Errorf("上传目标 %s 的模板无效: %v", config.SinkName(), err)
+			}
+		default:
+			return fmt.Errorf("未知的上传目标类型: %s", config.Type)
+		}
+	}
+	return nil
+}
+
+// 当前启用的上传目标，在监控启动时打开，监控结束时关闭
+var (
+	activeSinks []Sink
+	sinksMutex  sync.Mutex
+)
+
+// openSinks 按配置打开所有上传目标，未设置 API Key 时跳过提示词服务器，打开失败的目标会被跳过
+func openSinks(configManager *storage.ConfigManager, logger types.Logger) []Sink {
+	configs, err := configManager.LoadSinkConfigs()
+	if err != nil {
+		logger.Log(types.LogLevelError, "%v，只上传到提示词服务器", err)
+		configs = []storage.SinkConfig{{Type: storage.SinkServer}}
+	}
+
+	var sinks []Sink
+	for _, config := range configs {
+		if config.Type == storage.SinkServer && app.Config.ApiKey == "" {
+			logger.Log(types.LogLevelWarning, "未设置 API Key，跳过上传目标 %s", config.SinkName())
+			continue
+		}
+
+		sink, err := NewSink(config, logger)
+		if err != nil {
+			logger.Log(types.LogLevelError, "打开上传目标 %s 失败: %v", config.SinkName(), err)
+			continue
+		}
+		sinks = append(sinks, sink)
+		logger.Log(types.LogLevelInfo, "已启用上传目标: %s", sink.Name())
+	}
+	return sinks
+}
+
+// setActiveSinks 设置当前启用的上传目标
+func setActiveSinks(sinks []Sink) {
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+	activeSinks = sinks
+}
+
+// uploadSinkNames 返回 kind 类记录应发送到的上传目标名称，kind 为空时不按记录类型过滤，allowed 为 false 时只返回本地目标
+// 去重键按上传目标记录，只发送到本地目标的记录在规则改为允许后仍会发送到远程目标
+func uploadSinkNames(kind string, allowed bool) []string {
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+
+	names := make([]string, 0, len(activeSinks))
+	for _, sink := range activeSinks {
+		if !allowed && !sink.Local() {
+			continue
+		}
+		if filter, ok := sink.(kindFilter); ok && kind != "" && !filter.Accepts(kind) {
+			continue
+		}
+		names = append(names, sink.Name())
+	}
+	return names
+}
+
+// closeSinks 关闭上传目标
+func closeSinks(sinks []Sink, logger types.Logger) {
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			logger.Log(types.LogLevelError, "关闭上传目标 %s 失败: %v", sink.Name(), err)
+		}
+	}
+}
+
+// sameError 返回长度为 n、每项都是 err 的错误列表
+func sameError(n int, err error) []error {
+	errs := make([]error, n)
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
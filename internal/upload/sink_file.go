@@ -0,0 +1,72 @@
+package upload
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// fileSink 追加写入本地 JSONL 文件，每行格式为 {"kind": "...", "key": "...", "record": {...}}
+type fileSink struct {
+	name string
+	mu   sync.Mutex
+	file *os.File
+}
+
+func newFileSink(name, path string) (*fileSink, error) {
+	if path == "" {
+		return nil, fmt.Errorf("未指定文件路径")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建目录失败: %v", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开文件失败: %v", err)
+	}
+	return &fileSink{name: name, file: file}, nil
+}
+
+func (s *fileSink) Name() string {
+	return s.name
+}
+
+func (s *fileSink) Local() bool {
+	return true
+}
+
+func (s *fileSink) Send(records []Record) []error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	errs := make([]error, len(records))
+	for i, record := range records {
+		line, err := json.Marshal(map[string]interface{}{
+			"kind":   record.Kind,
+			"key":    record.Key,
+			"record": record.Payload,
+		})
+		if err != nil {
+			errs[i] = fmt.Errorf("JSON 编码失败: %v", err)
+			continue
+		}
+		if _, err := s.file.Write(append(line, '\n')); err != nil {
+			errs[i] = fmt.Errorf("写入文件失败: %v", err)
+		}
+	}
+
+	// 确保写入落盘后才标记为已发送
+	if err := s.file.Sync(); err != nil {
+		return sameError(len(records), fmt.Errorf("写入文件失败: %v", err))
+	}
+	return errs
+}
+
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
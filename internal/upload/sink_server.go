@@ -0,0 +1,191 @@
+package upload
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"cursor_history/internal/app"
+	"cursor_history/internal/types"
+)
+
+// BatchUploadPath 批量上传接口
+const BatchUploadPath = "/api/prompt/batch-upload"
+
+// httpClient 所有请求共用的 HTTP 客户端，复用连接并设置超时
+var httpClient = &http.Client{
+	Timeout: 60 * time.Second,
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          10,
+		MaxIdleConnsPerHost:   4,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+	},
+}
+
+// serverSink 上传到提示词服务器（app.Config.ServerURL）
+type serverSink struct {
+	name   string
+	logger types.Logger
+	// batchUnsupported 服务器对批量接口返回 404 后置为 true，之后只使用逐条上传
+	batchUnsupported atomic.Bool
+	// conversationsUnsupported 服务器对对话接口返回 404 后置为 true，之后不再上传对话
+	conversationsUnsupported atomic.Bool
+}
+
+func newServerSink(name string, logger types.Logger) *serverSink {
+	return &serverSink{name: name, logger: logger}
+}
+
+func (s *serverSink) Name() string {
+	return s.name
+}
+
+func (s *serverSink) Local() bool {
+	return false
+}
+
+func (s *serverSink) Close() error {
+	return nil
+}
+
+// Accepts 服务器不支持对话接口后不再接受对话
+func (s *serverSink) Accepts(kind string) bool {
+	return kind != KindConversation || !s.conversationsUnsupported.Load()
+}
+
+// Send 提示词通过批量接口发送，对话逐条发送到对话接口
+func (s *serverSink) Send(records []Record) []error {
+	errs := make([]error, len(records))
+
+	var prompts []int
+	for i, record := range records {
+		if record.Kind == KindConversation {
+			errs[i] = s.sendConversation(record.Payload)
+			continue
+		}
+		prompts = append(prompts, i)
+	}
+	if len(prompts) == 0 {
+		return errs
+	}
+
+	batch := make([]Record, 0, len(prompts))
+	for _, i := range prompts {
+		batch = append(batch, records[i])
+	}
+	for j, err := range s.sendPrompts(batch) {
+		errs[prompts[j]] = err
+	}
+	return errs
+}
+
+// sendConversation 发送对话，服务器没有对话接口（404）时记录一次警告，之后不再发送对话
+func (s *serverSink) sendConversation(payload []byte) error {
+	if s.conversationsUnsupported.Load() {
+		return errKindUnsupported
+	}
+
+	err := postPayload(apiURL(app.Config.ServerURL, ConversationUploadPath), payload)
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+		if s.conversationsUnsupported.CompareAndSwap(false, true) {
+			s.logger.Log(types.LogLevelWarning, "提示词服务器不支持对话接口 %s，不再上传对话到 %s", ConversationUploadPath, s.name)
+		}
+		return errKindUnsupported
+	}
+	return err
+}
+
+// sendPrompts 通过批量接口发送提示词
+// 服务器不支持批量接口（404）或拒绝整批请求时改为逐条发送，以便定位具体失败的记录
+func (s *serverSink) sendPrompts(batch []Record) []error {
+	sendEach := func() []error {
+		errs := make([]error, len(batch))
+		for i, record := range batch {
+			errs[i] = postPayload(app.Config.ServerURL, record.Payload)
+		}
+		return errs
+	}
+
+	if len(batch) == 1 || s.batchUnsupported.Load() {
+		return sendEach()
+	}
+
+	err := postBatch(apiURL(app.Config.ServerURL, BatchUploadPath), batch)
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) && !statusErr.retryable() {
+		if statusErr.StatusCode == http.StatusNotFound {
+			s.batchUnsupported.Store(true)
+		}
+		return sendEach()
+	}
+	return sameError(len(batch), err)
+}
+
+// postPayload 以 JSON 格式 POST 单条记录到服务器，非 200 状态返回 *httpStatusError
+func postPayload(url string, payload []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %v", err)
+	}
+	return doRequest(req)
+}
+
+// postBatch 把一批记录以 gzip 压缩的 JSON 发送到批量接口，格式为 {"items": [...]}
+func postBatch(url string, batch []Record) error {
+	items := make([]json.RawMessage, 0, len(batch))
+	for _, record := range batch {
+		items = append(items, record.Payload)
+	}
+	body, err := json.Marshal(map[string]interface{}{"items": items})
+	if err != nil {
+		return fmt.Errorf("JSON 编码失败: %v", err)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return fmt.Errorf("压缩请求失败: %v", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("压缩请求失败: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", url, &buf)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Encoding", "gzip")
+	return doRequest(req)
+}
+
+// doRequest 设置提示词服务器的公共请求头并发送请求，非 200 状态返回 *httpStatusError
+func doRequest(req *http.Request) error {
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", app.Config.ApiKey)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newHTTPStatusError(resp)
+	}
+	return nil
+}
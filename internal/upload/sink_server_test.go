@@ -0,0 +1,93 @@
+package upload
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"cursor_history/internal/app"
+	"cursor_history/internal/types"
+)
+
+// countingLogger 记录各级别日志的条数
+type countingLogger struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (l *countingLogger) Log(level string, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.counts == nil {
+		l.counts = make(map[string]int)
+	}
+	l.counts[level]++
+}
+
+func (l *countingLogger) Close() error {
+	return nil
+}
+
+func (l *countingLogger) count(level string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.counts[level]
+}
+
+// 服务器没有对话接口时只记录一次警告，之后不再请求对话接口，提示词照常上传
+func TestServerSinkConversationNotFound(t *testing.T) {
+	var conversationRequests, promptRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ConversationUploadPath) {
+			conversationRequests.Add(1)
+			http.NotFound(w, r)
+			return
+		}
+		promptRequests.Add(1)
+	}))
+	defer server.Close()
+
+	oldURL := app.Config.ServerURL
+	app.Config.ServerURL = server.URL + "/api/prompt/upload"
+	defer func() { app.Config.ServerURL = oldURL }()
+
+	logger := &countingLogger{}
+	sink := newServerSink("server", logger)
+	if !sink.Accepts(KindConversation) {
+		t.Fatal("请求对话接口之前不应拒绝对话")
+	}
+
+	records := []Record{
+		{Kind: KindConversation, Payload: []byte(`{}`)},
+		{Kind: KindConversation, Payload: []byte(`{}`)},
+		{Kind: KindPrompt, Payload: []byte(`{}`)},
+	}
+	for round := 0; round < 2; round++ {
+		errs := sink.Send(records)
+		for i := 0; i < 2; i++ {
+			if !errors.Is(errs[i], errKindUnsupported) {
+				t.Fatalf("第 %d 轮对话 %d 的错误为 %v，期望不支持", round, i, errs[i])
+			}
+		}
+		if errs[2] != nil {
+			t.Fatalf("第 %d 轮提示词上传失败: %v", round, errs[2])
+		}
+	}
+
+	if n := conversationRequests.Load(); n != 1 {
+		t.Fatalf("请求了 %d 次对话接口，期望 1 次", n)
+	}
+	if n := promptRequests.Load(); n != 2 {
+		t.Fatalf("上传了 %d 次提示词，期望 2 次", n)
+	}
+	if n := logger.count(types.LogLevelWarning); n != 1 {
+		t.Fatalf("记录了 %d 条警告，期望 1 条", n)
+	}
+	if sink.Accepts(KindConversation) || !sink.Accepts(KindPrompt) {
+		t.Fatal("服务器不支持对话接口后应只拒绝对话")
+	}
+}
@@ -0,0 +1,78 @@
+package upload
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"cursor_history/internal/storage"
+)
+
+// sqliteSink 把提示词保存到独立的本地 SQLite 归档，归档库只包含归档表，可以用 search -db 搜索
+type sqliteSink struct {
+	name    string
+	archive *storage.Archive
+}
+
+func newSQLiteSink(name, path string) (*sqliteSink, error) {
+	if path == "" {
+		return nil, fmt.Errorf("未指定数据库路径")
+	}
+	archive, err := storage.OpenArchive(path)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteSink{name: name, archive: archive}, nil
+}
+
+func (s *sqliteSink) Name() string {
+	return s.name
+}
+
+func (s *sqliteSink) Local() bool {
+	return true
+}
+
+func (s *sqliteSink) Close() error {
+	return s.archive.Close()
+}
+
+// Send 只归档提示词，其他类型的记录直接视为成功
+func (s *sqliteSink) Send(records []Record) []error {
+	errs := make([]error, len(records))
+	for i, record := range records {
+		if record.Kind != KindPrompt {
+			continue
+		}
+
+		var payload struct {
+			Value       string `json:"value"`
+			CommandType string `json:"commandType"`
+			Workspace   string `json:"workspace"`
+			Editor      string `json:"editor"`
+			Timestamp   int64  `json:"timestamp"`
+			Git         struct {
+				RemoteURL  string `json:"remoteUrl"`
+				CommitHash string `json:"commitHash"`
+				BranchName string `json:"branchName"`
+			} `json:"git"`
+		}
+		if err := json.Unmarshal(record.Payload, &payload); err != nil {
+			errs[i] = fmt.Errorf("解析记录失败: %v", err)
+			continue
+		}
+
+		commandType, _ := strconv.Atoi(payload.CommandType)
+		_, errs[i] = s.archive.ArchivePrompt(storage.ArchivedPrompt{
+			Text:        payload.Value,
+			CommandType: commandType,
+			Editor:      payload.Editor,
+			Workspace:   payload.Workspace,
+			GitRemote:   payload.Git.RemoteURL,
+			GitCommit:   payload.Git.CommitHash,
+			GitBranch:   payload.Git.BranchName,
+			Timestamp:   payload.Timestamp,
+		})
+	}
+	return errs
+}
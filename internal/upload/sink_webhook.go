@@ -0,0 +1,105 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/template"
+)
+
+// defaultWebhookTemplate 默认请求体，与 file 上传目标的每行格式相同
+const defaultWebhookTemplate = `{"kind": {{json .Kind}}, "key": {{json .Key}}, "record": {{json .Record}}}`
+
+// webhookData Webhook 模板可用的数据，Record 为上传记录的字段，如 {{.Record.value}}、{{.Record.workspace}}
+type webhookData struct {
+	Kind   string
+	Key    string // 去重键
+	MD5    string // 内容的 MD5，兼容旧模板中的 {{.MD5}}
+	Record map[string]interface{}
+}
+
+// parseWebhookTemplate 解析 Webhook 请求体模板，模板中可使用 json 函数输出 JSON 编码的值
+func parseWebhookTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		text = defaultWebhookTemplate
+	}
+	return template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}).Option("missingkey=zero").Parse(text)
+}
+
+// webhookSink 把每条记录按模板渲染后 POST 到指定地址，2xx 状态视为成功
+type webhookSink struct {
+	name     string
+	url      string
+	template *template.Template
+	headers  map[string]string
+}
+
+func newWebhookSink(name, url, text string, headers map[string]string) (*webhookSink, error) {
+	if url == "" {
+		return nil, fmt.Errorf("未指定 Webhook 地址")
+	}
+	tmpl, err := parseWebhookTemplate(text)
+	if err != nil {
+		return nil, fmt.Errorf("解析 Webhook 模板失败: %v", err)
+	}
+	return &webhookSink{name: name, url: url, template: tmpl, headers: headers}, nil
+}
+
+func (s *webhookSink) Name() string {
+	return s.name
+}
+
+func (s *webhookSink) Local() bool {
+	return false
+}
+
+func (s *webhookSink) Close() error {
+	return nil
+}
+
+func (s *webhookSink) Send(records []Record) []error {
+	errs := make([]error, len(records))
+	for i, record := range records {
+		errs[i] = s.send(record)
+	}
+	return errs
+}
+
+func (s *webhookSink) send(record Record) error {
+	data := webhookData{Kind: record.Kind, Key: record.Key}
+	if err := json.Unmarshal(record.Payload, &data.Record); err != nil {
+		return fmt.Errorf("解析记录失败: %v", err)
+	}
+	data.MD5, _ = data.Record["md5"].(string)
+
+	var body bytes.Buffer
+	if err := s.template.Execute(&body, data); err != nil {
+		return fmt.Errorf("渲染 Webhook 模板失败: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", s.url, &body)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newHTTPStatusError(resp)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"cursor_history/internal/source"
+	"cursor_history/internal/storage"
+	"cursor_history/internal/types"
+)
+
+// ErrNotMonitoring 当前没有运行中的监控
+var ErrNotMonitoring = errors.New("监控未运行")
+
+// Status 当前监控的状态
+type Status struct {
+	Monitoring bool
+	StartedAt  time.Time
+	Roots      []source.Root
+}
+
+// watchState 运行中的监控，补扫时复用其上传目标和配置
+type watchState struct {
+	ctx           context.Context
+	roots         []source.Root
+	configManager *storage.ConfigManager
+	logger        types.Logger
+	startedAt     time.Time
+	scans         sync.WaitGroup // 进行中的补扫
+}
+
+// currentWatch 运行中的监控，由 watcherMutex 保护
+var currentWatch *watchState
+
+// setCurrentWatch 设置运行中的监控，监控停止时设为 nil
+func setCurrentWatch(state *watchState) {
+	watcherMutex.Lock()
+	defer watcherMutex.Unlock()
+	currentWatch = state
+}
+
+// CurrentStatus 返回当前监控的状态
+func CurrentStatus() Status {
+	watcherMutex.Lock()
+	defer watcherMutex.Unlock()
+
+	if currentWatch == nil {
+		return Status{}
+	}
+	return Status{
+		Monitoring: true,
+		StartedAt:  currentWatch.startedAt,
+		Roots:      currentWatch.roots,
+	}
+}
+
+// Rescan 在运行中的监控里补扫已有的工作区数据库，没有运行中的监控时返回 ErrNotMonitoring
+// 监控停止时补扫在当前数据库处理完后中断
+func Rescan(opts ScanOptions) (ScanResult, error) {
+	watcherMutex.Lock()
+	state := currentWatch
+	if state != nil {
+		state.scans.Add(1)
+	}
+	watcherMutex.Unlock()
+	if state == nil {
+		return ScanResult{}, ErrNotMonitoring
+	}
+	defer state.scans.Done()
+
+	result := scanWorkspaces(state.ctx, state.roots, opts, state.configManager, componentLogger(state.logger, componentScan))
+	return result, state.ctx.Err()
+}
@@ -1,15 +1,21 @@
 package upload
 
 import (
-	"bytes"
+	"context"
 	"crypto/md5"
-	"cursor_history/internal/app"
+	"cursor_history/internal/debounce"
+	"cursor_history/internal/logging"
+	"cursor_history/internal/redact"
+	"cursor_history/internal/source"
 	"cursor_history/internal/storage"
 	"cursor_history/internal/types"
-	"database/sql"
+	"cursor_history/internal/vscdb"
+	"cursor_history/internal/workspace"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"net/http"
 	"net/url"
 	"os"
@@ -20,14 +26,11 @@ import (
 	"time"
 
 	"github.com/fsnotify/fsnotify"
-	"github.com/go-git/go-git/v5"
 )
 
 // ValidateApiKey 验证 API Token
 func ValidateApiKey(apiKey string, serverURL string) error {
-	// 从服务器 URL 中提取基础 URL
-	baseURL := serverURL[:len(serverURL)-len("/api/prompt/upload")]
-	validURL := baseURL + "/api/api-key/valid?key=" + url.QueryEscape(apiKey)
+	validURL := apiURL(serverURL, "/api/api-key/valid") + "?key=" + url.QueryEscape(apiKey)
 
 	// 创建请求
 	req, err := http.NewRequest("GET", validURL, nil)
@@ -39,8 +42,7 @@ func ValidateApiKey(apiKey string, serverURL string) error {
 	req.Header.Set("X-API-Key", apiKey)
 
 	// 发送请求
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("验证请求失败: %v", err)
 	}
@@ -54,118 +56,123 @@ func ValidateApiKey(apiKey string, serverURL string) error {
 	return nil
 }
 
-// WatchDirectory 监控目录变化
-func WatchDirectory(searchPath string, configManager *storage.ConfigManager, logger types.Logger) error {
-	// 使用缓冲通道
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return fmt.Errorf("创建文件监控失败: %v", err)
+// apiURL 把上传地址中的 /api/prompt/upload 替换为指定的接口路径
+func apiURL(serverURL string, path string) string {
+	return strings.TrimSuffix(serverURL, "/api/prompt/upload") + path
+}
+
+// WatchDirectory 监控目录变化，roots 为 source.Roots 解析出的各编辑器 workspaceStorage 目录
+func WatchDirectory(roots []source.Root, configManager *storage.ConfigManager, logger types.Logger) error {
+	return WatchDirectoryContext(configManager.GetContext(), roots, configManager, logger)
+}
+
+// WatchDirectoryContext 与 WatchDirectory 相同，ctx 取消时停止监控
+// 调用方持有 ctx 的取消函数，可以在监控启动之前就停止，不依赖 CloseWatcher
+func WatchDirectoryContext(ctx context.Context, roots []source.Root, configManager *storage.ConfigManager, logger types.Logger) error {
+	if len(roots) == 0 {
+		return fmt.Errorf("未找到 workspaceStorage 目录")
 	}
+	logger = withLogLevels(configManager, logger)
 
-	// 保存 watcher 到全局变量
+	// ctx 取消、CloseWatcher 或程序退出时停止监控
+	ctx, cancel := context.WithCancel(ctx)
 	watcherMutex.Lock()
-	currentWatcher = watcher
+	currentCancel = cancel
 	watcherMutex.Unlock()
-
-	// 确保在函数返回时关闭 watcher
 	defer func() {
+		cancel()
 		watcherMutex.Lock()
-		if currentWatcher == watcher {
-			currentWatcher = nil
-		}
+		currentCancel = nil
 		watcherMutex.Unlock()
+	}()
 
-		err := watcher.Close()
-		if err != nil {
-			logger.Log(types.LogLevelError, "关闭 watcher 失败: %v", err)
-		}
+	// 加载脱敏规则、上传规则和上传目标，并启动后台发送协程
+	stopPipeline := startPipeline(configManager, logger)
+	defer stopPipeline()
+
+	// 记录监控状态，供控制接口查询和补扫，停止前等待进行中的补扫结束
+	state := &watchState{ctx: ctx, roots: roots, configManager: configManager, logger: logger, startedAt: time.Now()}
+	setCurrentWatch(state)
+	defer func() {
+		setCurrentWatch(nil)
+		cancel()
+		state.scans.Wait()
 	}()
 
-	// 使用对象池来减少内存分配
-	fileInfoPool := sync.Pool{
-		New: func() interface{} {
-			return &FileInfo{}
-		},
+	// 同一文件的连续写入合并为一次处理，处理期间的新写入会在处理结束后再处理一次
+	watchSettings, err := configManager.LoadWatchSettings()
+	if err != nil {
+		logger.Log(types.LogLevelWarning, "%v，使用默认监控参数", err)
 	}
+	debouncer := debounce.New(debounce.RealClock{}, watchSettings.DebounceWindow, watchSettings.DebounceMaxWait, func(path string) {
+		processFile(FileInfo{Path: path, ModTime: time.Now().Unix()}, sourceForFile(roots, path), configManager, logger)
+	})
+	defer debouncer.Stop()
 
-	// 创建一个处理中的文件映射
-	processingFiles := make(map[string]bool)
-	var mu sync.Mutex
+	// 补扫监控启动前已有的工作区数据库，与文件监控同时进行
+	scanSettings, err := configManager.LoadScanSettings()
+	if err != nil {
+		logger.Log(types.LogLevelWarning, "%v，启动时补扫所有工作区数据库", err)
+	}
+	if scanSettings.OnStart {
+		scanDone := make(chan struct{})
+		go func() {
+			defer close(scanDone)
+			startupScan(ctx, roots, scanSettings.Since, configManager, logger)
+		}()
+		defer func() {
+			cancel()
+			<-scanDone
+		}()
+	}
 
-	// 创建一个 done 通道用于清理
-	done := make(chan struct{})
-	defer close(done)
+	// 监控目录，fsnotify 出错时自动重启或改为轮询
+	err = watchLoop(ctx, roots, watchSettings, debouncer.Trigger, logger)
+	if err != nil {
+		logger.Log(types.LogLevelError, "监控错误: %v", err)
+		return err
+	}
+	logger.Log(types.LogLevelInfo, "监控已停止")
+	return nil
+}
 
-	// 启动一个 goroutine 来处理文件事件
-	go func() {
-		for {
-			select {
-			case <-done:
-				return
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
-
-				// 如果有新目录创建，添加到监控
-				if event.Op&fsnotify.Create == fsnotify.Create {
-					if fi, err := os.Stat(event.Name); err == nil && fi.IsDir() {
-						if err := addWatchDir(watcher, event.Name, logger); err != nil {
-							logger.Log(types.LogLevelError, "添加新目录监控失败: %v", err)
-						}
-					}
-				}
-
-				if filepath.Base(event.Name) == "state.vscdb" && (event.Op&fsnotify.Write == fsnotify.Write) {
-					// 检查文件是否正在处理中
-					mu.Lock()
-					if processingFiles[event.Name] {
-						mu.Unlock()
-						continue
-					}
-					processingFiles[event.Name] = true
-					mu.Unlock()
-
-					// 处理文件
-					fileInfo := fileInfoPool.Get().(*FileInfo)
-					fileInfo.Path = event.Name
-					fileInfo.ModTime = time.Now().Unix()
-					processFile(*fileInfo, configManager, logger)
-
-					// 处理完成后移除标记
-					mu.Lock()
-					delete(processingFiles, event.Name)
-					mu.Unlock()
-
-					// 重置并释放文件信息
-					fileInfo.Path = ""
-					fileInfo.ModTime = 0
-					fileInfoPool.Put(fileInfo)
-				}
-			}
-		}
-	}()
+// startPipeline 加载脱敏规则、工作区上传规则和读取参数，打开上传目标并启动后台发送协程
+// 返回的函数停止发送协程并关闭上传目标
+func startPipeline(configManager *storage.ConfigManager, logger types.Logger) func() {
+	// 加载脱敏规则，提示词在归档和上传前脱敏
+	redactor := openRedactor(configManager, logger)
+	setActiveRedactor(redactor)
 
-	// 递归添加所有子目录到监控
-	if err := addWatchDir(watcher, searchPath, logger); err != nil {
-		return fmt.Errorf("添加目录监控失败: %v", err)
-	}
+	// 加载工作区上传规则
+	setActivePolicy(openPolicy(configManager, logger))
 
-	logger.Log(types.LogLevelInfo, "开始监控目录: %s", searchPath)
+	// 加载读取 state.vscdb 的参数、去重参数和要收集的 Git 信息
+	setReadOptions(loadReadOptions(configManager, logger))
+	setDedupeSettings(loadDedupeSettings(configManager, logger))
+	setGitFields(loadGitFields(configManager, logger))
+	gitCache := newGitCache(logger)
+	setGitCache(gitCache)
 
-	// 主循环监听停止信号
-	for {
-		select {
-		case err, ok := <-watcher.Errors:
-			if !ok {
-				return nil
-			}
-			logger.Log(types.LogLevelError, "监控错误: %v", err)
-			return fmt.Errorf("监控错误: %v", err)
+	// 打开上传目标，并启动后台发送协程发送上传队列中的记录
+	done := make(chan struct{})
+	senderExited := make(chan struct{})
+	sinks := openSinks(configManager, logger)
+	setActiveSinks(sinks)
+	go func() {
+		defer close(senderExited)
+		runSender(done, sinks, configManager, logger)
+	}()
 
-		case <-configManager.GetContext().Done():
-			logger.Log(types.LogLevelInfo, "监控已停止")
-			return nil
+	// 等待发送协程结束后再关闭上传目标，正在发送的批次不会使用已关闭的上传目标和配置数据库
+	return func() {
+		close(done)
+		<-senderExited
+		setGitCache(nil)
+		gitCache.Close()
+		setActiveSinks(nil)
+		closeSinks(sinks, logger)
+		if counts := redactor.Counts(); len(counts) > 0 {
+			logger.Log(types.LogLevelInfo, "本次运行共脱敏敏感信息: %s", redact.FormatCounts(counts))
 		}
 	}
 }
@@ -174,9 +181,9 @@ func WatchDirectory(searchPath string, configManager *storage.ConfigManager, log
 func addWatchDir(watcher *fsnotify.Watcher, dir string, logger types.Logger) error {
 	err := watcher.Add(dir)
 	if err != nil {
-		return fmt.Errorf("添加目录监控失败 %s: %v", dir, err)
+		return fmt.Errorf("添加目录监控失败 %s: %w", dir, err)
 	}
-	// logger.Log(types.LogLevelInfo, "添加目录监控: %s", dir)
+	logging.From(logger).Debug("添加目录监控", "dir", dir)
 
 	// 递归遍历子目录
 	entries, err := os.ReadDir(dir)
@@ -204,306 +211,318 @@ type FileInfo struct {
 	ModTime int64
 }
 
-// 添加新结构体用于解析 workspace.json
-type WorkspaceConfig struct {
-	Folder string `json:"folder"`
-}
+// processFile 处理文件，按来源定义的键读取 ItemTable 中的提示词
+func processFile(file FileInfo, src *source.Source, configManager *storage.ConfigManager, logger types.Logger) {
+	// 补扫和文件监控可能同时处理同一个文件，同一文件串行处理，避免重复上传
+	unlock := lockFile(file.Path)
+	defer unlock()
 
-// 修改 processWorkspaceJson 函数来保存 workspace 路径
-func processWorkspaceJson(path string) (string, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return "", fmt.Errorf("读取 workspace.json 失败: %v", err)
-	}
+	log := componentLogger(logger, componentProcess).With("path", file.Path)
+	logger = log
+	log.Debug("开始处理文件")
 
-	// 解析 JSON
-	var config WorkspaceConfig
-	if err := json.Unmarshal(data, &config); err != nil {
-		return "", fmt.Errorf("解析 workspace.json 失败: %v", err)
+	// 在扫描文件之前，先处理 workspace.json，没有 workspace.json 的空窗口不处理
+	ws, ok := loadWorkspace(file.Path, logger)
+	if !ok {
+		return
 	}
 
-	// 解码 URL 编码的路径
-	decodedFolder, err := url.QueryUnescape(strings.TrimPrefix(config.Folder, "file:///"))
-	if err != nil {
-		return "", fmt.Errorf("解码文件夹路径失败: %v", err)
+	// 一次读取来源定义的所有键，读取完成后立即关闭数据库，避免长时间占用编辑器的数据库
+	keys := make([]string, 0, len(src.Keys)+len(src.ConversationKeys))
+	for _, key := range src.Keys {
+		keys = append(keys, key.Name)
 	}
-
-	// fmt.Printf("工作区文件夹: %s\n", decodedFolder)
-	return decodedFolder, nil
-}
-
-// processFile 处理文件
-func processFile(file FileInfo, configManager *storage.ConfigManager, logger types.Logger) {
-	// 记录处理开始
-	// logger.Log(types.LogLevelInfo, "开始处理文件: %s", file.Path)
-
-	// 这里添加文件处理的具体逻辑
-	// 例如：读取文件内容，解析数据，上传到服务器等
-	// 在描文件之前，先处理 workspace.json
-	workspaceJsonPath := filepath.Join(filepath.Dir(file.Path), "workspace.json")
-	workspace, err := processWorkspaceJson(workspaceJsonPath)
-	if err != nil {
-		return
+	for _, key := range src.ConversationKeys {
+		keys = append(keys, key.Name)
 	}
-
-	// 打开数据库
-	db, err := sql.Open("sqlite3", file.Path)
+	values, err := vscdb.ReadValues(file.Path, "ItemTable", keys, currentReadOptions())
 	if err != nil {
-		logger.Log(types.LogLevelError, "无法打开数据库: %v", err)
+		logReadError(err, logger)
 		return
 	}
-	defer db.Close()
+	filesProcessed.Inc(src.Name)
 
-	// 查询所有表名
-	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type='table';")
+	// 上次处理时各键的状态，键值未变化时跳过，数组只追加时只处理新增条目
+	states, err := configManager.LoadFileState(file.Path)
 	if err != nil {
-		logger.Log(types.LogLevelError, "查询表名出错: %v", err)
-		return
+		logger.Log(types.LogLevelError, "%v", err)
+		states = map[string]storage.FileKeyState{}
 	}
-	defer rows.Close()
 
-	var tableName string
-	for rows.Next() {
-		err = rows.Scan(&tableName)
-		if err != nil {
-			logger.Log(types.LogLevelError, "扫描表名出错: %v", err)
+	for _, key := range src.Keys {
+		value, ok := values[key.Name]
+		if !ok {
 			continue
 		}
 
-		// 查询表数据
-		tableRows, err := db.Query(fmt.Sprintf("SELECT * FROM %s;", tableName))
-		if err != nil {
-			logger.Log(types.LogLevelError, "查询表数据出错: %v", err)
+		hash := valueHash(value)
+		prev := states[key.Name]
+		if prev.ValueHash == hash {
+			log.Debug("键值未变化，跳过", "key", key.Name)
 			continue
 		}
-		defer tableRows.Close()
 
-		// 获取列名
-		columns, err := tableRows.Columns()
-		if err != nil {
-			logger.Log(types.LogLevelError, "获取列名出错: %v", err)
+		state, ok := uploadPrompt(value, key, src.Name, prev, promptMeta{
+			Workspace:  ws,
+			SourceFile: file.Path,
+			SourceKey:  key.Name,
+			Timestamp:  file.ModTime,
+		}, configManager, logger)
+		if !ok {
 			continue
 		}
-
-		// 创建一个切片来存储每行的值
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-		for i := range values {
-			valuePtrs[i] = &values[i]
+		state.ValueHash = hash
+		if err := configManager.SaveFileKeyState(file.Path, key.Name, state); err != nil {
+			logger.Log(types.LogLevelError, "%v", err)
 		}
+	}
 
-		// 输出表数据
-		for tableRows.Next() {
-			err = tableRows.Scan(valuePtrs...)
-			if err != nil {
-				logger.Log(types.LogLevelError, "扫描行数据出错: %v", err)
-				continue
-			}
+	// 处理完整的聊天和 Composer 对话
+	if len(src.ConversationKeys) > 0 {
+		processConversations(values, file, src, states, configManager, ws, logger)
+	}
 
-			// 检查值中是否包含 "cursor"
-			containsCursor := false
-			for i, value := range values {
-				if strValue, ok := value.(string); ok && contains(strValue, "aiService.prompts") && columns[i] == "key" {
-					containsCursor = true
-					break
-				}
-			}
+	log.Debug("文件处理完成", "workspace", ws.Path())
+}
 
-			// 修改 uploadPrompt 的调用，传入 workspace 参数
-			if containsCursor {
-				for i, col := range columns {
-					if strValue, ok := values[i].(string); ok {
-						uploadPrompt(strValue, col, file.ModTime, configManager, workspace, logger)
-					}
-				}
-			}
-		}
+// fileLocks 正在处理的文件的锁
+var fileLocks sync.Map
+
+// lockFile 锁定文件的处理，返回解锁函数
+func lockFile(path string) func() {
+	value, _ := fileLocks.LoadOrStore(path, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// stateDBPath 返回事件文件对应的 state.vscdb 路径，不是 state.vscdb 或其 WAL 文件时返回 false
+func stateDBPath(name string) (string, bool) {
+	switch filepath.Base(name) {
+	case "state.vscdb":
+		return name, true
+	case "state.vscdb-wal":
+		return strings.TrimSuffix(name, "-wal"), true
 	}
+	return "", false
+}
 
-	// 记录处理结果
-	// logger.Log(types.LogLevelSuccess, "文件处理完成: %s", file.Path)
+// sourceForFile 根据文件所在的监控目录判断来源
+func sourceForFile(roots []source.Root, path string) *source.Source {
+	for _, root := range roots {
+		rel, err := filepath.Rel(root.Path, path)
+		if err == nil && !strings.HasPrefix(rel, "..") {
+			return root.Source
+		}
+	}
+	return source.ForPath(path)
 }
 
-// 检查字符串是否包含子字符串
-func contains(s, substr string) bool {
-	return strings.Contains(s, substr)
+// promptMeta 提示词的来源信息，随提示词一起归档和上传
+type promptMeta struct {
+	Workspace  workspace.Workspace // 工作区
+	SourceFile string              // 提示词所在的 state.vscdb
+	SourceKey  string              // 提示词所在的 ItemTable 键
+	Timestamp  int64               // 文件修改时间（秒）
+	Git        GitInfo             // 工作区的 Git 信息
+	Allowed    bool                // 工作区规则是否允许上传，为 false 时只发送到本地上传目标
 }
 
-// 修改 uploadPrompt 函数签名，添加 workspace 参数
-func uploadPrompt(value string, col string, timestamp int64, configManager *storage.ConfigManager, workspace string, logger types.Logger) {
-	if col == "key" {
-		return
+// loadWorkspace 读取 state.vscdb 所在目录的 workspace.json，没有 workspace.json 或无法解析时返回 false
+// 多根工作区的 .code-workspace 文件无法读取时记录警告，以 .code-workspace 文件所在的目录作为工作区
+func loadWorkspace(dbPath string, logger types.Logger) (workspace.Workspace, bool) {
+	ws, err := workspace.Load(filepath.Join(filepath.Dir(dbPath), "workspace.json"))
+	var foldersErr *workspace.FoldersError
+	switch {
+	case err == nil:
+		return ws, true
+	case errors.As(err, &foldersErr):
+		logger.Log(types.LogLevelWarning, "%v，使用工作区文件所在的目录 %s", err, ws.Path())
+		return ws, true
+	case errors.Is(err, fs.ErrNotExist):
+		logging.From(logger).Debug("没有 workspace.json，跳过")
+	default:
+		logger.Log(types.LogLevelWarning, "%v", err)
 	}
+	return ws, false
+}
 
-	uploadList, err := convertValueToUploadPrompt(value)
+// uploadPrompt 解析键对应的值并上传上次处理之后新增的提示词
+// prev 为上次处理时的状态：提示词数组只在末尾追加时只处理新增部分，否则全部重新处理（已上传的会按去重键跳过）
+// 返回本次处理后的状态和是否全部处理成功
+func uploadPrompt(value string, key source.Key, editor string, prev storage.FileKeyState, meta promptMeta, configManager *storage.ConfigManager, logger types.Logger) (storage.FileKeyState, bool) {
+	uploadList, err := convertValueToUploadPrompt(value, key, editor)
 	if err != nil {
 		logger.Log(types.LogLevelError, "转换值失败: %v", err)
-		return
+		return prev, false
+	}
+
+	state := storage.FileKeyState{Count: len(uploadList)}
+	if len(uploadList) > 0 {
+		state.TailHash = promptHash(uploadList[len(uploadList)-1])
+	}
+
+	start := 0
+	if prev.Count > 0 && prev.Count <= len(uploadList) && promptHash(uploadList[prev.Count-1]) == prev.TailHash {
+		start = prev.Count
+	}
+	if start == len(uploadList) {
+		return state, true
 	}
 
-	// 获取 Git 信息
-	gitInfo := getGitInfo(workspace, logger)
+	// 获取 Git 信息，远程工作区在本机无法访问时没有 Git 信息
+	meta.Git = getGitInfo(meta.Workspace.LocalPath(), logger)
+	meta.Allowed = workspaceAllowed(meta.Workspace.Path(), meta.Git.RemoteURL, logger)
 
-	for _, upload := range uploadList {
-		uploadSinglePrompt(upload, timestamp, configManager, workspace, logger, gitInfo)
+	ok := true
+	occurrences := promptOccurrences(uploadList)
+	for i := start; i < len(uploadList); i++ {
+		upload := redactPrompt(uploadList[i], logger)
+		if !uploadSinglePrompt(upload, occurrences[i], meta, configManager, logger) {
+			ok = false
+		}
 	}
+	return state, ok
+}
+
+// valueHash 计算键值的哈希，用于判断键值是否变化
+func valueHash(value string) string {
+	hash := md5.Sum([]byte(value))
+	return hex.EncodeToString(hash[:])
+}
+
+// promptHash 计算脱敏前提示词的哈希，用于确认提示词数组只是在末尾追加
+func promptHash(prompt UploadPrompt) string {
+	return valueHash(strconv.Itoa(prompt.CommandType) + "\x00" + prompt.Text)
 }
 
 type UploadPrompt struct {
 	Text        string `json:"text"`
 	CommandType int    `json:"commandType"`
+	Editor      string `json:"editor"`
 }
 
-func convertValueToUploadPrompt(value string) ([]UploadPrompt, error) {
-	var uploadList []UploadPrompt
-	err := json.Unmarshal([]byte(value), &uploadList)
-	return uploadList, err
+// convertValueToUploadPrompt 使用来源定义的解析函数把键值转换为待上传的提示词
+func convertValueToUploadPrompt(value string, key source.Key, editor string) ([]UploadPrompt, error) {
+	prompts, err := key.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %v", key.Name, err)
+	}
+
+	uploadList := make([]UploadPrompt, 0, len(prompts))
+	for _, prompt := range prompts {
+		uploadList = append(uploadList, UploadPrompt{
+			Text:        prompt.Text,
+			CommandType: prompt.CommandType,
+			Editor:      editor,
+		})
+	}
+	return uploadList, nil
 }
 
-// 修改 uploadSinglePrompt 函数签名，添加 workspace 参数
-func uploadSinglePrompt(prompt UploadPrompt, timestamp int64, configManager *storage.ConfigManager, workspace string, logger types.Logger, gitInfo GitInfo) {
-	// 计算MD5值
-	hash := md5.Sum([]byte(prompt.Text))
-	md5Value := hex.EncodeToString(hash[:])
+// uploadSinglePrompt 归档并上传单条提示词，没有启用的上传目标时只保存到本地归档
+// occurrence 为提示词数组中之前出现过几次相同的提示词，按日期去重时使用
+// 返回归档和加入上传队列是否都成功，失败时不记录文件状态，下次文件变化时重新处理
+func uploadSinglePrompt(prompt UploadPrompt, occurrence int, meta promptMeta, configManager *storage.ConfigManager, logger types.Logger) bool {
+	promptsExtracted.Inc(prompt.Editor)
+	archived := archivePrompt(prompt, meta, configManager, logger)
+	sinks := uploadSinkNames(KindPrompt, meta.Allowed)
+	if len(sinks) == 0 {
+		return archived
+	}
 
-	// 检查MD5是否已上传
-	exists, err := configManager.IsMD5Uploaded(md5Value)
+	// 按去重键检查各上传目标是否已发送过，全局去重时旧版本按文本 MD5 记录的也视为已发送
+	md5Value := textMD5(prompt.Text)
+	settings := currentDedupeSettings()
+	var day string
+	if settings.ByDay {
+		var err error
+		if day, err = promptFirstSeenDay(prompt, occurrence, meta, settings, configManager); err != nil {
+			logger.Log(types.LogLevelError, "%v", err)
+			return false
+		}
+	}
+	key := promptDedupeKey(prompt, meta, settings, day)
+	sinks, err := configManager.PendingSinks(sinks, dedupeKeys(key, md5Value, settings)...)
 	if err != nil {
-		logger.Log(types.LogLevelError, "检查MD5失败: %v", err)
-		return
+		logger.Log(types.LogLevelError, "%v", err)
+		return false
 	}
-	if exists {
-		// logger.Log(types.LogLevelInfo, "MD5已存在，跳过上传: %s", md5Value)
-		return
+	if len(sinks) == 0 {
+		duplicatesSkipped.Inc(KindPrompt)
+		logging.From(logger).Debug("提示词已上传，跳过", "key", key, "md5", md5Value, "workspace", meta.Workspace.Path())
+		return archived
 	}
 
 	// 准备请求数据
 	data := map[string]interface{}{
-		"value":       prompt.Text,
-		"commandType": strconv.Itoa(prompt.CommandType),
-		"md5":         md5Value,
-		"timestamp":   timestamp,
-		"workspace":   workspace,
-		"uploadTime":  time.Now().UnixMilli(),
-		"git": map[string]interface{}{
-			"isGitRepo":  gitInfo.IsGitRepo,
-			"remoteUrl":  gitInfo.RemoteURL,
-			"commitHash": gitInfo.CommitHash,
-			"branchName": gitInfo.BranchName,
-		},
-	}
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		logger.Log(types.LogLevelError, "JSON 编码失败: %v", err)
-		return
+		"value":         prompt.Text,
+		"commandType":   strconv.Itoa(prompt.CommandType),
+		"md5":           md5Value,
+		"dedupeKey":     key,
+		"timestamp":     meta.Timestamp,
+		"workspace":     meta.Workspace.Path(),
+		"workspaceInfo": meta.Workspace,
+		"editor":        prompt.Editor,
+		"uploadTime":    time.Now().UnixMilli(),
+		"git":           meta.Git,
 	}
-
-	// 创建请求，使用 Config.ServerURL 而不是 app.ServerURL
-	req, err := http.NewRequest("POST", app.Config.ServerURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		logger.Log(types.LogLevelError, "创建请求失败: %v", err)
-		return
+	queued := enqueueUpload(sinks, KindPrompt, key, data, fmt.Sprintf("%v %v", prompt.Text, prompt.CommandType), configManager, logger)
+	if queued {
+		logging.From(logger).Debug("提示词已加入上传队列", "key", key, "md5", md5Value, "workspace", meta.Workspace.Path(), "sinks", strings.Join(sinks, ","))
 	}
+	return archived && queued
+}
 
-	// 设置请求头
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-API-Key", app.Config.ApiKey) // 使用 appConfig.Token
-
-	// 发送请求
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// archivePrompt 保存提示词到本地归档，返回是否成功，归档失败不影响上传
+func archivePrompt(prompt UploadPrompt, meta promptMeta, configManager *storage.ConfigManager, logger types.Logger) bool {
+	_, err := configManager.ArchivePrompt(storage.ArchivedPrompt{
+		Text:        prompt.Text,
+		CommandType: prompt.CommandType,
+		Editor:      prompt.Editor,
+		Workspace:   meta.Workspace.Path(),
+		GitRemote:   meta.Git.RemoteURL,
+		GitCommit:   meta.Git.CommitHash,
+		GitBranch:   meta.Git.BranchName,
+		SourceFile:  meta.SourceFile,
+		Timestamp:   meta.Timestamp,
+	})
 	if err != nil {
-		logger.Log(types.LogLevelError, "上传失败: %v", err)
-		return
+		logger.Log(types.LogLevelError, "%v", err)
+		return false
 	}
-	defer resp.Body.Close()
+	return true
+}
 
-	// 检查响应状态
-	if resp.StatusCode != http.StatusOK {
-		logger.Log(types.LogLevelError, "服务器返回错误状态: %d", resp.StatusCode)
-		return
+// enqueueUpload 把待上传的数据加入各上传目标的持久化队列，由后台发送协程负责发送和重试
+func enqueueUpload(sinks []string, kind string, dedupeKey string, data interface{}, summary string, configManager *storage.ConfigManager, logger types.Logger) bool {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		logger.Log(types.LogLevelError, "JSON 编码失败: %v", err)
+		return false
 	}
 
-	// 上传成功后保存MD5
-	if err := configManager.SaveMD5(md5Value); err != nil {
-		logger.Log(types.LogLevelError, "保存MD5失败: %v", err)
-		return
+	if _, err := configManager.EnqueueOutbox(sinks, kind, dedupeKey, payload, summary); err != nil {
+		logger.Log(types.LogLevelError, "%v", err)
+		return false
 	}
-
-	logger.Log(types.LogLevelSuccess, "成功上传: %v %v", prompt.Text, prompt.CommandType)
+	notifySender()
+	return true
 }
 
-// 添加一个全局的 watcher 变量和互斥锁
+// 当前监控的停止函数，watcherMutex 同时保护 currentWatch
 var (
-	currentWatcher *fsnotify.Watcher
-	watcherMutex   sync.Mutex
+	currentCancel context.CancelFunc
+	watcherMutex  sync.Mutex
 )
 
-// 添加关闭 watcher 的函数
+// CloseWatcher 停止当前的监控，WatchDirectory 随后返回
 func CloseWatcher() {
 	watcherMutex.Lock()
 	defer watcherMutex.Unlock()
 
-	if currentWatcher != nil {
-		currentWatcher.Close()
-		currentWatcher = nil
+	if currentCancel != nil {
+		currentCancel()
+		currentCancel = nil
 	}
 }
-
-// GitInfo 结构体定义
-type GitInfo struct {
-	IsGitRepo  bool   `json:"isGitRepo"`
-	RemoteURL  string `json:"remoteUrl"`
-	CommitHash string `json:"commitHash"`
-	BranchName string `json:"branchName"`
-}
-
-// 获取 Git 信息的函数
-func getGitInfo(workspace string, logger types.Logger) GitInfo {
-	info := GitInfo{
-		IsGitRepo: false,
-	}
-
-	// 递归向上查找 .git 目录
-	currentPath := workspace
-	for {
-		// 尝试打开 Git 仓库
-		repo, err := git.PlainOpen(currentPath)
-		if err == nil {
-			info.IsGitRepo = true
-
-			// 获取远程仓库信息
-			remotes, err := repo.Remotes()
-			if err == nil && len(remotes) > 0 {
-				if urls := remotes[0].Config().URLs; len(urls) > 0 {
-					info.RemoteURL = urls[0]
-				}
-			}
-
-			// 获取当前 HEAD
-			head, err := repo.Head()
-			if err == nil {
-				info.CommitHash = head.Hash().String()
-
-				// 获取分支名
-				if head.Name().IsBranch() {
-					info.BranchName = head.Name().Short()
-				}
-			}
-
-			// logger.Log(types.LogLevelInfo, "找到 Git 仓库: %s", currentPath)
-			break
-		}
-
-		// 获取父目录
-		parentPath := filepath.Dir(currentPath)
-		if parentPath == currentPath {
-			// 已经到达根目录，仍未找到 .git
-			// logger.Log(types.LogLevelInfo, "未找到 Git 仓库: %s", workspace)
-			break
-		}
-		currentPath = parentPath
-	}
-
-	return info
-}
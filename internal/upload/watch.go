@@ -0,0 +1,252 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"cursor_history/internal/logging"
+	"cursor_history/internal/source"
+	"cursor_history/internal/storage"
+	"cursor_history/internal/types"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// 文件监控重启参数
+const (
+	watchRestartMinDelay = time.Second     // 第一次重启前的等待时间，之后每次翻倍
+	watchRestartMaxDelay = time.Minute     // 重启前的最长等待时间
+	watchStableDuration  = 5 * time.Minute // 监控持续运行超过该时间后重置重启计数
+	maxWatchRestarts     = 5               // auto 模式下连续重启超过该次数后改为轮询
+	catchUpMargin        = 10 * time.Second
+)
+
+// errNoWatchableDirs 所有目录都无法监控
+var errNoWatchableDirs = errors.New("没有可监控的目录")
+
+// fileStamp state.vscdb 及其 WAL 文件的修改时间和大小，任一变化都表示数据库有新内容
+type fileStamp struct {
+	ModTime    time.Time
+	Size       int64
+	WALModTime time.Time
+	WALSize    int64
+}
+
+// latest 返回数据库和 WAL 中较新的修改时间
+func (s fileStamp) latest() time.Time {
+	if s.WALModTime.After(s.ModTime) {
+		return s.WALModTime
+	}
+	return s.ModTime
+}
+
+// watchLoop 按监控方式监控目录，直到 ctx 取消
+// fsnotify 出错后自动重启，重启时补处理出错期间修改的文件；auto 模式下 fsnotify 不可用或频繁出错时改为轮询
+func watchLoop(ctx context.Context, roots []source.Root, settings storage.WatchSettings, trigger func(path string), logger types.Logger) error {
+	logger = componentLogger(logger, componentWatcher)
+	if settings.Mode == storage.WatchModePoll {
+		return runPoller(ctx, roots, settings.PollInterval, time.Time{}, trigger, logger)
+	}
+
+	var since time.Time // 上次出错的时间，重启后补处理此后修改的文件
+	restarts := 0
+	delay := watchRestartMinDelay
+	for {
+		started := time.Now()
+		err := runFSNotify(ctx, roots, since, trigger, logger)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errors.Is(err, errNoWatchableDirs) {
+			return err
+		}
+		watcherErrors.Inc()
+
+		since = time.Now().Add(-catchUpMargin)
+		if settings.Mode == storage.WatchModeAuto && isWatchUnavailable(err) {
+			logger.Log(types.LogLevelWarning, "文件监控不可用: %v，改为每 %v 轮询一次", err, settings.PollInterval)
+			return runPoller(ctx, roots, settings.PollInterval, since, trigger, logger)
+		}
+
+		if time.Since(started) > watchStableDuration {
+			restarts = 0
+			delay = watchRestartMinDelay
+		}
+		restarts++
+		if settings.Mode == storage.WatchModeAuto && restarts > maxWatchRestarts {
+			logger.Log(types.LogLevelWarning, "文件监控连续出错 %d 次: %v，改为每 %v 轮询一次", restarts-1, err, settings.PollInterval)
+			return runPoller(ctx, roots, settings.PollInterval, since, trigger, logger)
+		}
+
+		logger.Log(types.LogLevelWarning, "%v，%v 后重启文件监控", err, delay)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > watchRestartMaxDelay {
+			delay = watchRestartMaxDelay
+		}
+	}
+}
+
+// isWatchUnavailable 判断错误是否表示 fsnotify 在当前系统上无法使用，如 inotify 监控数量或实例数量达到上限
+func isWatchUnavailable(err error) bool {
+	return errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EMFILE)
+}
+
+// runFSNotify 使用 fsnotify 监控目录，直到 ctx 取消（返回 nil）或监控出错（返回错误）
+// since 不为零时先处理该时间之后修改过的文件，用于补上重启前漏掉的事件
+func runFSNotify(ctx context.Context, roots []source.Root, since time.Time, trigger func(path string), logger types.Logger) error {
+	log := logging.From(logger)
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("创建文件监控失败: %w", err)
+	}
+	defer func() {
+		if err := watcher.Close(); err != nil {
+			logger.Log(types.LogLevelError, "关闭 watcher 失败: %v", err)
+		}
+	}()
+
+	// 递归添加所有子目录到监控，部分目录失败时继续监控其他目录
+	watched := 0
+	for _, root := range roots {
+		if err := addWatchDir(watcher, root.Path, logger); err != nil {
+			if isWatchUnavailable(err) {
+				return err
+			}
+			watcherErrors.Inc()
+			logger.Log(types.LogLevelError, "添加目录监控失败: %v", err)
+			continue
+		}
+		watched++
+		logger.Log(types.LogLevelInfo, "开始监控目录: %s (%s)", root.Path, root.Source.Name)
+	}
+	if watched == 0 {
+		return errNoWatchableDirs
+	}
+	watchedDirs.Set(float64(len(watcher.WatchList())))
+	defer watchedDirs.Set(0)
+
+	if !since.IsZero() {
+		for path, stamp := range scanStateFiles(roots) {
+			if !stamp.latest().Before(since) {
+				log.Debug("补处理监控重启前修改的文件", "path", path)
+				trigger(path)
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return fmt.Errorf("文件监控已关闭")
+			}
+
+			// 如果有新目录创建，添加到监控
+			if event.Op&fsnotify.Create == fsnotify.Create {
+				if fi, err := os.Stat(event.Name); err == nil && fi.IsDir() {
+					if err := addWatchDir(watcher, event.Name, logger); err != nil {
+						if isWatchUnavailable(err) {
+							return err
+						}
+						watcherErrors.Inc()
+						logger.Log(types.LogLevelError, "添加新目录监控失败: %v", err)
+					}
+					watchedDirs.Set(float64(len(watcher.WatchList())))
+				}
+			}
+
+			// state.vscdb 和它的 WAL 文件变化都合并到 state.vscdb 的处理
+			if dbPath, ok := stateDBPath(event.Name); ok && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+				log.Debug("数据库文件变化", "path", dbPath, "event", event.Op.String())
+				trigger(dbPath)
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return fmt.Errorf("文件监控已关闭")
+			}
+			return fmt.Errorf("监控错误: %w", err)
+		}
+	}
+}
+
+// runPoller 定期扫描目录，比较 state.vscdb 及其 WAL 的修改时间和大小，直到 ctx 取消
+// since 不为零时第一次扫描处理该时间之后修改过的文件，否则第一次扫描只记录文件状态
+func runPoller(ctx context.Context, roots []source.Root, interval time.Duration, since time.Time, trigger func(path string), logger types.Logger) error {
+	log := logging.From(logger)
+	for _, root := range roots {
+		logger.Log(types.LogLevelInfo, "开始轮询目录: %s (%s)，间隔 %v", root.Path, root.Source.Name, interval)
+	}
+	watchedDirs.Set(float64(len(roots)))
+	defer watchedDirs.Set(0)
+
+	stamps := scanStateFiles(roots)
+	if !since.IsZero() {
+		for path, stamp := range stamps {
+			if !stamp.latest().Before(since) {
+				log.Debug("补处理改为轮询前修改的文件", "path", path)
+				trigger(path)
+			}
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		current := scanStateFiles(roots)
+		for path, stamp := range current {
+			if prev, ok := stamps[path]; !ok || prev != stamp {
+				log.Debug("数据库文件变化", "path", path, "size", stamp.Size, "walSize", stamp.WALSize)
+				trigger(path)
+			}
+		}
+		stamps = current
+	}
+}
+
+// scanStateFiles 扫描各目录下一级子目录中的 state.vscdb，返回其修改时间和大小
+func scanStateFiles(roots []source.Root) map[string]fileStamp {
+	stamps := make(map[string]fileStamp)
+	for _, root := range roots {
+		entries, err := os.ReadDir(root.Path)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			path := filepath.Join(root.Path, entry.Name(), "state.vscdb")
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+
+			stamp := fileStamp{ModTime: info.ModTime(), Size: info.Size()}
+			if wal, err := os.Stat(path + "-wal"); err == nil {
+				stamp.WALModTime = wal.ModTime()
+				stamp.WALSize = wal.Size()
+			}
+			stamps[path] = stamp
+		}
+	}
+	return stamps
+}
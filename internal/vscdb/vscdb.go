@@ -0,0 +1,322 @@
+// Package vscdb 以不干扰编辑器的方式读取 VS Code 系编辑器的 state.vscdb
+//
+// 编辑器在运行时持续写入 state.vscdb，直接以读写方式打开可能持有锁并导致编辑器写入失败。
+// 这里只用只读 URI 打开数据库，存在 WAL 文件时先把数据库和 WAL 复制到临时快照再读取，
+// 并对被占用或读到不完整数据的情况重试。
+package vscdb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3" // 导入 sqlite3 驱动
+)
+
+// 读取方式
+const (
+	ModeAuto     = "auto"     // 有 WAL 的小文件读快照，其余以只读方式打开
+	ModeSnapshot = "snapshot" // 总是复制数据库和 WAL 到临时快照后读取
+	ModeReadOnly = "readonly" // 总是以只读方式直接打开，遵守编辑器的锁
+)
+
+// ErrorKind 读取失败的类型
+type ErrorKind string
+
+// 读取失败的类型
+const (
+	KindMissing ErrorKind = "missing" // 文件不存在
+	KindLocked  ErrorKind = "locked"  // 数据库被编辑器占用
+	KindCorrupt ErrorKind = "corrupt" // 数据库损坏或读到写入一半的数据
+	KindOther   ErrorKind = "other"
+)
+
+// Error 读取 state.vscdb 失败
+type Error struct {
+	Kind     ErrorKind
+	Path     string
+	Attempts int // 已尝试次数
+	Err      error
+}
+
+func (e *Error) Error() string {
+	var reason string
+	switch e.Kind {
+	case KindMissing:
+		reason = "文件不存在"
+	case KindLocked:
+		reason = "数据库被占用"
+	case KindCorrupt:
+		reason = "数据库损坏或正在写入"
+	default:
+		reason = "读取失败"
+	}
+	if e.Attempts > 1 {
+		return fmt.Sprintf("%s: %s（已尝试 %d 次）: %v", e.Path, reason, e.Attempts, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %v", e.Path, reason, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Options 读取参数
+type Options struct {
+	Mode            string
+	BusyTimeout     time.Duration // 遇到锁时 sqlite 内部的忙等待时间
+	Retries         int           // 被占用或读到不完整数据时的重试次数
+	RetryDelay      time.Duration // 第一次重试前的等待时间，之后每次翻倍
+	SnapshotMaxSize int64         // auto 模式下复制快照的最大数据库大小，超过时以只读方式直接打开
+}
+
+// DefaultOptions 默认读取参数
+func DefaultOptions() Options {
+	return Options{
+		Mode:            ModeAuto,
+		BusyTimeout:     2 * time.Second,
+		Retries:         3,
+		RetryDelay:      200 * time.Millisecond,
+		SnapshotMaxSize: 64 << 20,
+	}
+}
+
+// ValidateMode 校验读取方式
+func ValidateMode(mode string) error {
+	switch mode {
+	case ModeAuto, ModeSnapshot, ModeReadOnly:
+		return nil
+	}
+	return fmt.Errorf("无效的读取方式: %s，可用: %s、%s、%s", mode, ModeAuto, ModeSnapshot, ModeReadOnly)
+}
+
+// DB 打开的 state.vscdb，读取快照时关闭后删除快照
+type DB struct {
+	db          *sql.DB
+	path        string
+	snapshotDir string
+}
+
+// Open 打开 state.vscdb，被占用或读到不完整数据时按 opts 重试
+func Open(path string, opts Options) (*DB, error) {
+	var d *DB
+	err := retry(path, opts, func() error {
+		var err error
+		d, err = open(path, opts)
+		return err
+	})
+	return d, err
+}
+
+// ReadValues 打开数据库读取指定表中的多个键后立即关闭，整个过程失败时按 opts 重试
+// 返回的结果中不包含不存在的键
+func ReadValues(path, table string, keys []string, opts Options) (map[string]string, error) {
+	var values map[string]string
+	err := retry(path, opts, func() error {
+		d, err := open(path, opts)
+		if err != nil {
+			return err
+		}
+		defer d.Close()
+
+		values, err = d.Values(table, keys)
+		return err
+	})
+	return values, err
+}
+
+// Values 读取表中的多个键，返回的结果中不包含不存在的键
+func (d *DB) Values(table string, keys []string) (map[string]string, error) {
+	values := make(map[string]string, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(keys)), ",")
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		args[i] = key
+	}
+
+	rows, err := d.db.Query(fmt.Sprintf("SELECT key, value FROM %s WHERE key IN (%s)", table, placeholders), args...)
+	if err != nil {
+		return nil, classify(d.path, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, classify(d.path, err)
+		}
+		values[key] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, classify(d.path, err)
+	}
+	return values, nil
+}
+
+// Value 读取表中的单个键，键不存在时返回 false
+func (d *DB) Value(table, key string) (string, bool, error) {
+	var value string
+	err := d.db.QueryRow(fmt.Sprintf("SELECT value FROM %s WHERE key = ?", table), key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, classify(d.path, err)
+	}
+	return value, true, nil
+}
+
+// Close 关闭数据库并删除快照
+func (d *DB) Close() error {
+	err := d.db.Close()
+	d.removeSnapshot()
+	return err
+}
+
+// retry 执行 fn，被占用或读到不完整数据时等待后重试
+func retry(path string, opts Options, fn func() error) error {
+	delay := opts.RetryDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		var readErr *Error
+		if !errors.As(err, &readErr) {
+			readErr = &Error{Kind: KindOther, Path: path, Err: err}
+		}
+		readErr.Attempts = attempt
+		if attempt > opts.Retries || (readErr.Kind != KindLocked && readErr.Kind != KindCorrupt) {
+			return readErr
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// open 按读取方式打开数据库，并读取一次 schema 确认数据库可用
+func open(path string, opts Options) (*DB, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, classify(path, err)
+	}
+
+	walInfo, walErr := os.Stat(path + "-wal")
+	hasWAL := walErr == nil && walInfo.Size() > 0
+
+	d := &DB{path: path}
+	source := path
+	params := url.Values{}
+	params.Set("mode", "ro")
+	params.Set("_busy_timeout", fmt.Sprint(opts.BusyTimeout.Milliseconds()))
+
+	// 不使用快照时以只读方式直接打开；编辑器随时可能开始写入，不能以不可变方式打开，遇到锁时等待 _busy_timeout
+	if opts.Mode == ModeSnapshot || (opts.Mode == ModeAuto && hasWAL && info.Size() <= opts.SnapshotMaxSize) {
+		// 快照中的 WAL 需要回放，以读写方式打开；快照是私有副本，不会与编辑器争用锁
+		if d.snapshotDir, source, err = snapshot(path, hasWAL); err != nil {
+			return nil, classify(path, err)
+		}
+		params.Del("mode")
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+(&url.URL{Path: filepath.ToSlash(source)}).EscapedPath()+"?"+params.Encode())
+	if err != nil {
+		d.removeSnapshot()
+		return nil, classify(path, err)
+	}
+	d.db = db
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master").Scan(&count); err != nil {
+		d.Close()
+		return nil, classify(path, err)
+	}
+	return d, nil
+}
+
+// removeSnapshot 删除快照目录
+func (d *DB) removeSnapshot() {
+	if d.snapshotDir != "" {
+		os.RemoveAll(d.snapshotDir)
+	}
+}
+
+// snapshot 把数据库和 WAL 复制到临时目录，返回临时目录和快照数据库路径
+func snapshot(path string, withWAL bool) (string, string, error) {
+	dir, err := os.MkdirTemp("", "cursor_history-vscdb-*")
+	if err != nil {
+		return "", "", fmt.Errorf("创建快照目录失败: %v", err)
+	}
+
+	target := filepath.Join(dir, filepath.Base(path))
+	if err := copyFile(path, target); err != nil {
+		os.RemoveAll(dir)
+		return "", "", err
+	}
+	if withWAL {
+		if err := copyFile(path+"-wal", target+"-wal"); err != nil && !os.IsNotExist(err) {
+			os.RemoveAll(dir)
+			return "", "", err
+		}
+	}
+	return dir, target, nil
+}
+
+// copyFile 复制文件
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// classify 根据错误判断读取失败的类型
+func classify(path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var readErr *Error
+	if errors.As(err, &readErr) {
+		return err
+	}
+
+	// 按 sqlite 的错误信息判断，不依赖驱动的错误类型，未启用 cgo 时也能编译
+	kind := KindOther
+	message := err.Error()
+	switch {
+	case os.IsNotExist(err):
+		kind = KindMissing
+	case strings.Contains(message, "database is locked"), strings.Contains(message, "database table is locked"):
+		kind = KindLocked
+	case strings.Contains(message, "malformed"), strings.Contains(message, "file is not a database"):
+		kind = KindCorrupt
+	case strings.Contains(message, "unable to open database file"):
+		if _, statErr := os.Stat(path); os.IsNotExist(statErr) {
+			kind = KindMissing
+		}
+	}
+	return &Error{Kind: kind, Path: path, Err: err}
+}
@@ -0,0 +1,371 @@
+// Package workspace 解析编辑器 workspaceStorage 中 workspace.json 记录的工作区地址
+//
+// workspace.json 中 folder 为单文件夹工作区的地址，workspace 为多根工作区 .code-workspace 文件的地址。
+// 地址可能是本地的 file URI，也可能是 Remote-SSH、WSL、Dev Container 等远程地址（vscode-remote://）。
+package workspace
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Kind 地址类型
+type Kind string
+
+// 地址类型
+const (
+	KindLocal        Kind = "local"              // 本机目录
+	KindSSH          Kind = "ssh-remote"         // Remote-SSH
+	KindWSL          Kind = "wsl"                // WSL 发行版
+	KindDevContainer Kind = "dev-container"      // Dev Container，对应本机的一个目录
+	KindContainer    Kind = "attached-container" // 附加到已运行的容器
+	KindRemote       Kind = "remote"             // 其他远程类型，如 Codespaces、Remote Tunnels
+)
+
+// Location 工作区文件夹或 .code-workspace 文件的地址
+type Location struct {
+	URI      string `json:"uri,omitempty"`
+	Kind     Kind   `json:"kind"`
+	Host     string `json:"host,omitempty"`     // 远程主机名、WSL 发行版或容器名
+	Path     string `json:"path"`               // 本机地址为本机路径，远程地址为远程主机上以 / 分隔的路径
+	HostPath string `json:"hostPath,omitempty"` // Dev Container 对应的本机目录
+}
+
+// LocalPath 返回本机上可以访问的路径，远程地址无法在本机访问时返回空字符串
+func (l Location) LocalPath() string {
+	switch l.Kind {
+	case KindLocal:
+		return l.Path
+	case KindDevContainer:
+		return l.HostPath
+	case KindWSL:
+		// Windows 上可以通过 \\wsl$ 访问 WSL 发行版中的文件
+		if runtime.GOOS == "windows" && l.Host != "" {
+			return `\\wsl$\` + l.Host + filepath.FromSlash(l.Path)
+		}
+	}
+	return ""
+}
+
+// Name 返回地址的唯一名称，本机地址为路径，远程地址为 类型+主机:路径
+func (l Location) Name() string {
+	if l.Kind == KindLocal {
+		return l.Path
+	}
+	return string(l.Kind) + "+" + l.Host + ":" + l.Path
+}
+
+// Workspace workspace.json 描述的工作区
+type Workspace struct {
+	File    *Location  `json:"file,omitempty"` // 多根工作区的 .code-workspace 文件，单文件夹工作区为 nil
+	Folders []Location `json:"folders"`        // 工作区包含的文件夹，无法读取远程 .code-workspace 文件时为空
+}
+
+// MultiRoot 返回是否为多根工作区
+func (w Workspace) MultiRoot() bool {
+	return w.File != nil
+}
+
+// Primary 返回工作区的主文件夹，即第一个文件夹；没有文件夹时返回 .code-workspace 文件所在的目录
+func (w Workspace) Primary() Location {
+	if len(w.Folders) > 0 {
+		return w.Folders[0]
+	}
+	if w.File != nil {
+		dir := *w.File
+		dir.Path = dirOf(dir)
+		dir.URI = ""
+		return dir
+	}
+	return Location{Kind: KindLocal}
+}
+
+// Path 返回主文件夹的路径，用于工作区规则匹配和上传数据
+func (w Workspace) Path() string {
+	return w.Primary().Path
+}
+
+// LocalPath 返回主文件夹在本机上可以访问的路径，用于读取 Git 信息，远程工作区返回空字符串
+func (w Workspace) LocalPath() string {
+	return w.Primary().LocalPath()
+}
+
+// Name 返回主文件夹的唯一名称，用于区分不同主机上相同路径的工作区
+func (w Workspace) Name() string {
+	return w.Primary().Name()
+}
+
+// FoldersError 无法读取多根工作区 .code-workspace 文件中的文件夹，Load 同时返回没有文件夹的工作区，
+// 主文件夹为 .code-workspace 文件所在的目录
+type FoldersError struct {
+	Err error
+}
+
+func (e *FoldersError) Error() string {
+	return e.Err.Error()
+}
+
+// Load 读取 workspace.json，多根工作区的 .code-workspace 文件在本机可以访问时读取其中的文件夹列表
+// workspace.json 不存在时返回的错误包装 fs.ErrNotExist；.code-workspace 文件不存在或无法解析时返回 *FoldersError
+func Load(path string) (Workspace, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Workspace{}, fmt.Errorf("读取 workspace.json 失败: %w", err)
+	}
+
+	var config struct {
+		Folder    string `json:"folder"`
+		Workspace string `json:"workspace"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Workspace{}, fmt.Errorf("解析 workspace.json 失败: %v", err)
+	}
+
+	switch {
+	case config.Folder != "":
+		folder, err := ParseURI(config.Folder)
+		if err != nil {
+			return Workspace{}, err
+		}
+		return Workspace{Folders: []Location{folder}}, nil
+
+	case config.Workspace != "":
+		file, err := ParseURI(config.Workspace)
+		if err != nil {
+			return Workspace{}, err
+		}
+		w := Workspace{File: &file}
+		if local := file.LocalPath(); local != "" {
+			if w.Folders, err = loadFolders(local, file); err != nil {
+				return w, &FoldersError{Err: err}
+			}
+		}
+		return w, nil
+	}
+	return Workspace{}, fmt.Errorf("workspace.json 中没有 folder 或 workspace: %s", path)
+}
+
+// loadFolders 读取 .code-workspace 文件中的文件夹，相对路径相对于 .code-workspace 文件所在的目录
+func loadFolders(localPath string, file Location) ([]Location, error) {
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取工作区文件失败: %v", err)
+	}
+
+	var config struct {
+		Folders []struct {
+			Path string `json:"path"`
+			URI  string `json:"uri"`
+		} `json:"folders"`
+	}
+	if err := json.Unmarshal(stripJSONC(data), &config); err != nil {
+		return nil, fmt.Errorf("解析工作区文件失败 %s: %v", localPath, err)
+	}
+
+	folders := make([]Location, 0, len(config.Folders))
+	for _, f := range config.Folders {
+		if f.URI != "" {
+			folder, err := ParseURI(f.URI)
+			if err != nil {
+				return nil, err
+			}
+			folders = append(folders, folder)
+			continue
+		}
+		if f.Path == "" {
+			continue
+		}
+
+		folder := file
+		folder.URI = ""
+		folder.Path = joinPath(file, f.Path)
+		folders = append(folders, folder)
+	}
+	return folders, nil
+}
+
+// ParseURI 解析 file:// 或 vscode-remote:// 地址，没有协议时视为本机路径
+func ParseURI(raw string) (Location, error) {
+	scheme, rest, ok := strings.Cut(raw, "://")
+	if !ok {
+		return Location{URI: raw, Kind: KindLocal, Path: filepath.Clean(raw)}, nil
+	}
+
+	// authority 中的 + 通常被编码为 %2B，net/url 不接受主机名中的编码字符，这里手动拆分
+	authority, rawPath, _ := strings.Cut(rest, "/")
+	if i := strings.IndexAny(rawPath, "?#"); i >= 0 {
+		rawPath = rawPath[:i]
+	}
+	authority, err := url.PathUnescape(authority)
+	if err != nil {
+		return Location{}, fmt.Errorf("解析工作区地址失败 %s: %v", raw, err)
+	}
+	p, err := url.PathUnescape("/" + rawPath)
+	if err != nil {
+		return Location{}, fmt.Errorf("解析工作区地址失败 %s: %v", raw, err)
+	}
+
+	switch strings.ToLower(scheme) {
+	case "file":
+		return Location{URI: raw, Kind: KindLocal, Path: filePath(authority, p)}, nil
+	case "vscode-remote":
+		loc := parseAuthority(authority)
+		loc.URI = raw
+		loc.Path = p
+		return loc, nil
+	}
+	return Location{URI: raw, Kind: KindRemote, Host: scheme + "://" + authority, Path: p}, nil
+}
+
+// filePath 把 file URI 的主机和路径转换为本机路径
+func filePath(host, p string) string {
+	switch {
+	case host != "" && host != "localhost":
+		// UNC 路径 file://server/share/dir
+		return filepath.FromSlash("//" + host + p)
+	case len(p) >= 3 && p[0] == '/' && p[2] == ':' && isLetter(p[1]):
+		// Windows 盘符路径 file:///c%3A/Users
+		return filepath.FromSlash(p[1:])
+	}
+	return filepath.FromSlash(p)
+}
+
+// parseAuthority 解析 vscode-remote 地址的 authority，格式为 <类型>+<参数>
+func parseAuthority(authority string) Location {
+	kind, arg, _ := strings.Cut(authority, "+")
+	switch Kind(kind) {
+	case KindSSH:
+		// 主机名含特殊字符时参数为 {"hostName": "..."} 的十六进制编码
+		host := arg
+		if obj, ok := decodeHexJSON(arg).(map[string]interface{}); ok {
+			if name, ok := obj["hostName"].(string); ok {
+				host = name
+			}
+		}
+		return Location{Kind: KindSSH, Host: host}
+
+	case KindWSL:
+		return Location{Kind: KindWSL, Host: arg}
+
+	case KindDevContainer:
+		// 参数为 {"hostPath": "..."} 或本机路径的十六进制编码
+		loc := Location{Kind: KindDevContainer}
+		switch v := decodeHexJSON(arg).(type) {
+		case map[string]interface{}:
+			loc.HostPath, _ = v["hostPath"].(string)
+		case string:
+			loc.HostPath = v
+		}
+		if loc.HostPath != "" {
+			loc.Host = filepath.Base(loc.HostPath)
+		}
+		return loc
+
+	case KindContainer:
+		// 参数为 {"containerName": "/name"} 的十六进制编码
+		loc := Location{Kind: KindContainer, Host: arg}
+		if obj, ok := decodeHexJSON(arg).(map[string]interface{}); ok {
+			if name, ok := obj["containerName"].(string); ok {
+				loc.Host = strings.TrimPrefix(name, "/")
+			}
+		}
+		return loc
+	}
+	return Location{Kind: KindRemote, Host: authority}
+}
+
+// decodeHexJSON 解码十六进制编码的 JSON 或字符串，不是十六进制编码时返回 nil
+func decodeHexJSON(s string) interface{} {
+	data, err := hex.DecodeString(s)
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err == nil {
+		return v
+	}
+	return string(data)
+}
+
+// joinPath 把 .code-workspace 中的文件夹路径解析为绝对路径，远程地址按 / 分隔处理
+func joinPath(file Location, p string) string {
+	if file.Kind == KindLocal {
+		if filepath.IsAbs(p) {
+			return filepath.Clean(p)
+		}
+		return filepath.Join(filepath.Dir(file.Path), p)
+	}
+	p = strings.ReplaceAll(p, `\`, "/")
+	if path.IsAbs(p) {
+		return path.Clean(p)
+	}
+	return path.Join(path.Dir(file.Path), p)
+}
+
+// dirOf 返回地址所在的目录
+func dirOf(l Location) string {
+	if l.Kind == KindLocal {
+		return filepath.Dir(l.Path)
+	}
+	return path.Dir(l.Path)
+}
+
+func isLetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// stripJSONC 去掉 .code-workspace 文件中的注释和末尾多余的逗号，得到标准 JSON
+func stripJSONC(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			out = append(out, c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i])
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		switch {
+		case c == '"':
+			inString = true
+			out = append(out, c)
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			i--
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			i += 2
+			for i+1 < len(data) && !(data[i] == '*' && data[i+1] == '/') {
+				i++
+			}
+			i++
+		case c == '}' || c == ']':
+			// 去掉右括号前的逗号
+			j := len(out) - 1
+			for j >= 0 && strings.ContainsRune(" \t\r\n", rune(out[j])) {
+				j--
+			}
+			if j >= 0 && out[j] == ',' {
+				out = append(out[:j], out[j+1:]...)
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,232 @@
+package workspace
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseURI(t *testing.T) {
+	sshHost := hex.EncodeToString([]byte(`{"hostName":"dev@build.example.com"}`))
+	devContainer := hex.EncodeToString([]byte(`{"hostPath":"/home/dev/app","configFile":{"$mid":1,"path":"/home/dev/app/.devcontainer/devcontainer.json","scheme":"file"}}`))
+	devContainerPath := hex.EncodeToString([]byte(`/home/dev/service`))
+	container := hex.EncodeToString([]byte(`{"containerName":"/redis-cache"}`))
+
+	tests := []struct {
+		uri  string
+		want Location
+	}{
+		{
+			uri:  "file:///home/dev/project",
+			want: Location{Kind: KindLocal, Path: filepath.FromSlash("/home/dev/project")},
+		},
+		{
+			uri:  "file:///c%3A/Users/dev/My%20Project",
+			want: Location{Kind: KindLocal, Path: filepath.FromSlash("c:/Users/dev/My Project")},
+		},
+		{
+			uri:  "file://fileserver/share/project",
+			want: Location{Kind: KindLocal, Path: filepath.FromSlash("//fileserver/share/project")},
+		},
+		{
+			uri:  "file://localhost/home/dev/project",
+			want: Location{Kind: KindLocal, Path: filepath.FromSlash("/home/dev/project")},
+		},
+		{
+			uri:  "vscode-remote://ssh-remote%2Bbuild-server/home/dev/project",
+			want: Location{Kind: KindSSH, Host: "build-server", Path: "/home/dev/project"},
+		},
+		{
+			uri:  "vscode-remote://ssh-remote+" + sshHost + "/srv/app",
+			want: Location{Kind: KindSSH, Host: "dev@build.example.com", Path: "/srv/app"},
+		},
+		{
+			uri:  "vscode-remote://wsl%2BUbuntu-22.04/home/dev/project",
+			want: Location{Kind: KindWSL, Host: "Ubuntu-22.04", Path: "/home/dev/project"},
+		},
+		{
+			uri:  "vscode-remote://dev-container%2B" + devContainer + "/workspaces/app",
+			want: Location{Kind: KindDevContainer, Host: "app", Path: "/workspaces/app", HostPath: "/home/dev/app"},
+		},
+		{
+			uri:  "vscode-remote://dev-container+" + devContainerPath + "/workspaces/service",
+			want: Location{Kind: KindDevContainer, Host: "service", Path: "/workspaces/service", HostPath: "/home/dev/service"},
+		},
+		{
+			uri:  "vscode-remote://attached-container%2B" + container + "/data",
+			want: Location{Kind: KindContainer, Host: "redis-cache", Path: "/data"},
+		},
+		{
+			uri:  "vscode-remote://codespaces%2Bcurly-train-123/workspaces/repo?x=1",
+			want: Location{Kind: KindRemote, Host: "codespaces+curly-train-123", Path: "/workspaces/repo"},
+		},
+		{
+			uri:  "vscode-vfs://github/org/repo",
+			want: Location{Kind: KindRemote, Host: "vscode-vfs://github", Path: "/org/repo"},
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParseURI(tt.uri)
+		if err != nil {
+			t.Fatalf("解析 %s 失败: %v", tt.uri, err)
+		}
+		tt.want.URI = tt.uri
+		if got != tt.want {
+			t.Fatalf("%s 解析为 %+v，期望 %+v", tt.uri, got, tt.want)
+		}
+	}
+
+	if _, err := ParseURI("file:///bad%zzpath"); err == nil {
+		t.Fatal("包含无效转义的地址应当解析失败")
+	}
+}
+
+func TestStripJSONC(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"行注释", "{\"a\": 1 // 注释\n}", "{\"a\": 1 \n}"},
+		{"块注释", `{/* 注释 */"a": 1}`, `{"a": 1}`},
+		{"字符串中的注释符号", `{"uri": "file:///a/*b*/c//d"}`, `{"uri": "file:///a/*b*/c//d"}`},
+		{"转义的引号和反斜杠", `{"a": "x\"// y\\", "b": 2, // 注释` + "\n}", `{"a": "x\"// y\\", "b": 2 ` + "\n}"},
+		{"对象末尾的逗号", `{"a": 1,}`, `{"a": 1}`},
+		{"数组末尾的逗号", "[1, 2,\n\t]", "[1, 2\n\t]"},
+		{"右括号前的块注释", `["a", /* 已移除 */ ]`, `["a"  ]`},
+		{"字符串中的逗号和括号", `{"a": ",]"}`, `{"a": ",]"}`},
+	}
+	for _, tt := range tests {
+		if got := string(stripJSONC([]byte(tt.in))); got != tt.want {
+			t.Fatalf("%s: %q 处理为 %q，期望 %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+// writeFile 写入测试文件
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fileURI 把本机路径转换为 workspace.json 中的 file URI
+func fileURI(path string) string {
+	path = filepath.ToSlash(path)
+	if len(path) >= 2 && path[1] == ':' {
+		path = "/" + path
+	}
+	return "file://" + path
+}
+
+func TestLoadFolder(t *testing.T) {
+	dir := t.TempDir()
+	project := filepath.Join(dir, "my project")
+	jsonPath := filepath.Join(dir, "workspace.json")
+	writeFile(t, jsonPath, `{"folder": "`+fileURI(filepath.Join(dir, "my%20project"))+`"}`)
+
+	w, err := Load(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.MultiRoot() || w.Path() != project || w.LocalPath() != project || w.Name() != project {
+		t.Fatalf("工作区为 %+v，期望单文件夹 %s", w, project)
+	}
+
+	writeFile(t, jsonPath, `{"folder": "vscode-remote://ssh-remote%2Bbuild-server/home/dev/project"}`)
+	if w, err = Load(jsonPath); err != nil {
+		t.Fatal(err)
+	}
+	if w.LocalPath() != "" || w.Name() != "ssh-remote+build-server:/home/dev/project" {
+		t.Fatalf("远程工作区为 %+v，名称 %s", w, w.Name())
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing", "workspace.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("workspace.json 不存在时返回 %v", err)
+	}
+}
+
+func TestLoadCodeWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "repos", "all.code-workspace")
+	writeFile(t, file, `// 多根工作区
+{
+	"folders": [
+		{
+			"path": "frontend", // 相对于工作区文件
+		},
+		{
+			"name": "共享库",
+			"path": "../libs/shared"
+		},
+		{
+			"uri": "vscode-remote://ssh-remote%2Bbuild-server/srv/api",
+		},
+		/* {
+			"path": "archived"
+		}, */
+	],
+	"settings": {
+		"files.exclude": {"**/node_modules": true},
+		"editor.rulers": [80, 120,],
+	},
+}
+`)
+	jsonPath := filepath.Join(dir, "workspace.json")
+	writeFile(t, jsonPath, `{"workspace": "`+fileURI(file)+`"}`)
+
+	w, err := Load(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !w.MultiRoot() || w.File.Path != file {
+		t.Fatalf("工作区文件为 %+v，期望 %s", w.File, file)
+	}
+	want := []string{
+		filepath.Join(dir, "repos", "frontend"),
+		filepath.Join(dir, "libs", "shared"),
+		"ssh-remote+build-server:/srv/api",
+	}
+	if len(w.Folders) != len(want) {
+		t.Fatalf("文件夹为 %+v，期望 %v", w.Folders, want)
+	}
+	for i, folder := range w.Folders {
+		if folder.Name() != want[i] {
+			t.Fatalf("第 %d 个文件夹为 %s，期望 %s", i, folder.Name(), want[i])
+		}
+	}
+	if w.Path() != want[0] {
+		t.Fatalf("主文件夹为 %s，期望 %s", w.Path(), want[0])
+	}
+
+	// .code-workspace 文件不存在时返回 *FoldersError，主文件夹为其所在的目录
+	writeFile(t, jsonPath, `{"workspace": "`+fileURI(filepath.Join(dir, "gone", "old.code-workspace"))+`"}`)
+	w, err = Load(jsonPath)
+	var foldersErr *FoldersError
+	if !errors.As(err, &foldersErr) {
+		t.Fatalf("工作区文件不存在时返回 %v，期望 *FoldersError", err)
+	}
+	if w.Path() != filepath.Join(dir, "gone") {
+		t.Fatalf("主文件夹为 %s，期望工作区文件所在的目录", w.Path())
+	}
+}
+
+// 远程 .code-workspace 文件无法读取，文件夹为空，主文件夹为其所在的远程目录
+func TestLoadRemoteCodeWorkspace(t *testing.T) {
+	jsonPath := filepath.Join(t.TempDir(), "workspace.json")
+	writeFile(t, jsonPath, `{"workspace": "vscode-remote://ssh-remote%2Bbuild-server/home/dev/all.code-workspace"}`)
+
+	w, err := Load(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.Folders) != 0 || w.Name() != "ssh-remote+build-server:/home/dev" {
+		t.Fatalf("远程多根工作区为 %+v，名称 %s", w, w.Name())
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+
+	"cursor_history/internal/cli"
+)
+
+// runGUI 非 Windows 平台没有桌面界面，打印命令行用法
+func runGUI() {
+	cli.Usage(os.Stderr)
+	os.Exit(2)
+}
@@ -0,0 +1,215 @@
+//go:build windows
+
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"cursor_history/internal/app"
+	"cursor_history/internal/control"
+	"cursor_history/internal/gui"
+	"cursor_history/internal/logging"
+	"cursor_history/internal/metrics"
+	"cursor_history/internal/storage"
+
+	"github.com/lxn/win"
+)
+
+// logFileName 日志文件名，位于可执行文件所在目录
+const logFileName = "cursor.log"
+
+var (
+	kernel32                = syscall.NewLazyDLL("kernel32.dll")
+	user32                  = syscall.NewLazyDLL("user32.dll")
+	procCreateMutex         = kernel32.NewProc("CreateMutexW")
+	procFindWindow          = user32.NewProc("FindWindowW")
+	procShowWindow          = user32.NewProc("ShowWindow")
+	procSetForegroundWindow = user32.NewProc("SetForegroundWindow")
+)
+
+func createMutex(name string) (syscall.Handle, error) {
+	ret, _, err := procCreateMutex.Call(
+		0,
+		0,
+		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr(name))),
+	)
+
+	if ret == 0 {
+		return 0, err
+	}
+
+	return syscall.Handle(ret), nil
+}
+
+func findAndActivateExistingWindow() bool {
+	// 查找已存在的窗口
+	hwnd, _, _ := procFindWindow.Call(
+		0,
+		uintptr(unsafe.Pointer(syscall.StringToUTF16Ptr("Cursor History"))),
+	)
+
+	if hwnd != 0 {
+		// 显示窗口
+		procShowWindow.Call(hwnd, win.SW_RESTORE)
+		// 将窗口置于前台
+		procSetForegroundWindow.Call(hwnd)
+		return true
+	}
+	return false
+}
+
+// runGUI 以 Windows 桌面模式运行（窗口 + 托盘）
+func runGUI() {
+	// 获取可执行文件路径
+	exePath, err := os.Executable()
+	if err != nil {
+		log.Fatal("获取可执行文件路径失败:", err)
+	}
+	exeDir := filepath.Dir(exePath)
+
+	// 切换工作目录到可执行文件所在目录
+	if err := os.Chdir(exeDir); err != nil {
+		log.Fatal("切换工作目录失败:", err)
+	}
+
+	// 设置日志输出，先使用默认的轮转参数，加载配置后再更新
+	fileLogger, err := logging.NewFileLogger(logging.DefaultFileOptions(logFileName))
+	if err != nil {
+		log.Fatal("无法创建日志文件:", err)
+	}
+	defer fileLogger.Close()
+	log.SetFlags(0)
+	log.SetOutput(fileLogger)
+
+	// 记录启动信息和路径
+	log.Printf("应用程序启动")
+	log.Printf("可执行文件路径: %s", exePath)
+	log.Printf("工作目录: %s", exeDir)
+
+	// 创建命名互斥锁
+	mutex, err := createMutex("Global\\CursorHistory")
+	if err != nil {
+		log.Fatal("创建互斥锁失败:", err)
+	}
+	defer syscall.CloseHandle(mutex)
+
+	// 检查是否已经有实例在运行
+	if syscall.GetLastError() == syscall.ERROR_ALREADY_EXISTS {
+		log.Println("程序已经在运行")
+		return
+	}
+
+	if findAndActivateExistingWindow() {
+		log.Println("程序已经在运行，已激活现有窗口")
+		return
+	}
+
+	// 初始化应用配置
+	app.InitApp("prod") // 默认使用 prod，但如果有环境变量则使用环境变量的值
+	log.Println("应用配置初始化完成")
+
+	// 获取并创建应用配置目录
+	configDir, err := storage.DefaultConfigDir()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("配置目录: %s", configDir)
+
+	// 初始化数据库
+	dbPath := filepath.Join(configDir, "config.db")
+	log.Printf("数据库路径: %s", dbPath)
+
+	configManager, err := storage.NewConfigManager(dbPath)
+	if err != nil {
+		log.Fatal("初始化配置管理器失败:", err)
+	}
+	defer configManager.Close()
+	log.Println("配置管理器初始化完成")
+	if !configManager.FullTextSearch() {
+		log.Println("当前程序编译时未启用 sqlite_fts5，本地归档的搜索不使用全文索引，改为逐条 LIKE 匹配")
+	}
+
+	if opts, err := configManager.LoadLogOptions(logFileName); err != nil {
+		log.Printf("加载日志配置失败，使用默认配置: %v", err)
+	} else if err := fileLogger.Configure(opts); err != nil {
+		log.Printf("更新日志配置失败: %v", err)
+	}
+
+	// 创建 GUI
+	mainWindow, err := gui.NewGUI(configManager)
+	if err != nil {
+		log.Fatal("创建窗口失败:", err)
+	}
+	log.Println("GUI 创建完成")
+
+	// 启用控制接口时保留最近的日志供查询
+	controlAddr, err := configManager.LoadSetting(storage.SettingControlAddr)
+	if err != nil {
+		log.Printf("加载控制接口配置失败: %v", err)
+	}
+	if controlAddr == "" {
+		mainWindow.SetLogOutput(fileLogger)
+	} else {
+		logs := control.NewLogBuffer(control.LogBufferSize)
+		mainWindow.SetLogOutput(logging.Multi(fileLogger, logs))
+		server, err := control.Start(control.Options{
+			Addr:          controlAddr,
+			ConfigManager: configManager,
+			Monitor:       mainWindow,
+			Logs:          logs,
+			Logger:        mainWindow,
+		})
+		if err != nil {
+			mainWindow.Log(gui.LogLevelError, "启动控制接口失败: %v", err)
+		} else {
+			defer server.Close()
+		}
+	}
+
+	// 启用指标接口时提供 Prometheus 指标
+	metricsAddr, err := configManager.LoadSetting(storage.SettingMetricsAddr)
+	if err != nil {
+		log.Printf("加载指标接口配置失败: %v", err)
+	}
+	if metricsAddr != "" {
+		server, err := metrics.Start(metricsAddr, mainWindow)
+		if err != nil {
+			mainWindow.Log(gui.LogLevelError, "启动指标接口失败: %v", err)
+		} else {
+			defer server.Close()
+		}
+	}
+
+	// 创建托盘图标
+	tray, err := mainWindow.NewTray()
+	if err != nil {
+		log.Fatal("创建托盘图标失败:", err)
+	}
+	defer tray.Dispose()
+	log.Println("托盘图标创建完成")
+
+	// 检查是否是开机启动
+	isAutoStart := false
+	for _, arg := range os.Args {
+		if strings.Contains(strings.ToLower(arg), "autostart") {
+			isAutoStart = true
+			break
+		}
+	}
+
+	// 如果是开机启动，则隐藏主窗口
+	if isAutoStart {
+		mainWindow.Hide()
+		log.Println("开机启动模式，窗口已隐藏")
+	}
+
+	// 运行主窗口
+	log.Println("开始运行主窗口")
+	mainWindow.Run()
+	log.Println("应用程序退出")
+}